@@ -0,0 +1,171 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+const listPageSize = 100
+
+// APIError 是 RAGFlow 以非 0 code 返回的业务错误
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+}
+
+// DocumentChunk 是 chunk 列表接口返回的 chunk, 按其在文档中的位置排序
+type DocumentChunk struct {
+	ID                string   `json:"id"`
+	Content           string   `json:"content"`
+	DocumentID        string   `json:"document_id"`
+	DatasetID         string   `json:"dataset_id"`
+	ImageID           string   `json:"image_id"`
+	ImportantKeywords []string `json:"important_keywords"`
+	Available         bool     `json:"available"`
+}
+
+type listChunksResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Chunks []DocumentChunk `json:"chunks"`
+		Total  int64           `json:"total"`
+	} `json:"data"`
+}
+
+func (r *Retriever) apiURL(path string, query url.Values) string {
+	u := strings.TrimRight(r.config.Endpoint, "/") + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+type apiResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// getJSON 发送 GET 请求并解析响应, RAGFlow 在业务失败时同样返回 200, 此时返回 *APIError
+func (r *Retriever) getJSON(ctx context.Context, u string, res any) error {
+	body, _, err := r.send(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	status := &apiResponse{}
+	if err = sonic.Unmarshal(body, status); err == nil && status.Code != 0 {
+		return &APIError{Code: status.Code, Message: status.Message}
+	}
+	if err = sonic.Unmarshal(body, res); err != nil {
+		return fmt.Errorf("decode response failed: %w", err)
+	}
+	return nil
+}
+
+// ListChunks 按文档内顺序列出文档的全部 chunk
+func (r *Retriever) ListChunks(ctx context.Context, datasetID, documentID string) ([]DocumentChunk, error) {
+	path := fmt.Sprintf("/datasets/%s/documents/%s/chunks", url.PathEscape(datasetID), url.PathEscape(documentID))
+	var chunks []DocumentChunk
+	for page := 1; ; page++ {
+		res := &listChunksResponse{}
+		err := r.getJSON(ctx, r.apiURL(path, url.Values{
+			"page":      {strconv.Itoa(page)},
+			"page_size": {strconv.Itoa(listPageSize)},
+		}), res)
+		if err != nil {
+			return nil, fmt.Errorf("list chunks failed: %w", err)
+		}
+		chunks = append(chunks, res.Data.Chunks...)
+		if len(res.Data.Chunks) < listPageSize || int64(len(chunks)) >= res.Data.Total {
+			return chunks, nil
+		}
+	}
+}
+
+// Dataset 是数据集列表接口返回的数据集
+type Dataset struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	EmbeddingModel string `json:"embedding_model"`
+	ChunkCount     int64  `json:"chunk_count"`
+	DocumentCount  int64  `json:"document_count"`
+}
+
+// DatasetDocument 是文档列表接口返回的文档
+type DatasetDocument struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	DatasetID  string `json:"dataset_id"`
+	Run        string `json:"run"`
+	ChunkCount int64  `json:"chunk_count"`
+}
+
+type listDatasetsResponse struct {
+	Code    int       `json:"code"`
+	Message string    `json:"message"`
+	Data    []Dataset `json:"data"`
+}
+
+type listDocumentsResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Docs  []DatasetDocument `json:"docs"`
+		Total int64             `json:"total"`
+	} `json:"data"`
+}
+
+// ListDatasets 列出当前 API Key 可访问的数据集, name 不为空时只返回同名数据集
+func (r *Retriever) ListDatasets(ctx context.Context, name string) ([]Dataset, error) {
+	var datasets []Dataset
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":      {strconv.Itoa(page)},
+			"page_size": {strconv.Itoa(listPageSize)},
+		}
+		if name != "" {
+			query.Set("name", name)
+		}
+		res := &listDatasetsResponse{}
+		if err := r.getJSON(ctx, r.apiURL("/datasets", query), res); err != nil {
+			return nil, fmt.Errorf("list datasets failed: %w", err)
+		}
+		datasets = append(datasets, res.Data...)
+		if len(res.Data) < listPageSize {
+			return datasets, nil
+		}
+	}
+}
+
+// ListDocuments 列出数据集中的文档, keywords 不为空时只返回名称包含 keywords 的文档
+func (r *Retriever) ListDocuments(ctx context.Context, datasetID, keywords string) ([]DatasetDocument, error) {
+	path := fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetID))
+	var docs []DatasetDocument
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":      {strconv.Itoa(page)},
+			"page_size": {strconv.Itoa(listPageSize)},
+		}
+		if keywords != "" {
+			query.Set("keywords", keywords)
+		}
+		res := &listDocumentsResponse{}
+		if err := r.getJSON(ctx, r.apiURL(path, query), res); err != nil {
+			return nil, fmt.Errorf("list documents failed: %w", err)
+		}
+		docs = append(docs, res.Data.Docs...)
+		if len(res.Data.Docs) < listPageSize || int64(len(docs)) >= res.Data.Total {
+			return docs, nil
+		}
+	}
+}
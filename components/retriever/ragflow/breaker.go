@@ -0,0 +1,220 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// ErrCircuitOpen 表示熔断器处于打开状态, 请求未发出即失败
+var ErrCircuitOpen = errors.New("ragflow circuit breaker is open")
+
+// errRateLimitWait 表示请求在等待限流器时失败, 请求并未发出
+var errRateLimitWait = errors.New("wait for rate limiter failed")
+
+// breakerOutcome 是一次请求对熔断器的影响
+type breakerOutcome int
+
+const (
+	breakerSuccess breakerOutcome = iota
+	breakerFailure
+	// breakerNeutral 既不计入成功也不计入失败, 例如调用方取消或请求未发出
+	breakerNeutral
+)
+
+// CircuitState 是熔断器的状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitTransition 是一次熔断器状态变化
+type CircuitTransition struct {
+	From CircuitState `json:"from"`
+	To   CircuitState `json:"to"`
+	At   time.Time    `json:"at"`
+}
+
+// CircuitBreakerConfig 定义了检索请求熔断器的参数
+type CircuitBreakerConfig struct {
+	// FailureThreshold 连续失败多少次后打开熔断器, 默认 5
+	FailureThreshold int
+	// OpenTimeout 熔断器打开后经过多久进入半开状态, 默认 30s
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests 半开状态下允许的探测请求数, 全部成功后关闭熔断器, 默认 1
+	HalfOpenMaxRequests int
+	// OnStateChange 状态变化时的回调, 可选
+	OnStateChange func(transition CircuitTransition)
+}
+
+// circuitBreaker 只统计服务端故障: 网络错误、超时、5xx 与 429; 业务错误和其他 4xx 不计入失败,
+// 调用方取消和等待限流器失败既不计入失败也不计入成功
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+func newCircuitBreaker(config *CircuitBreakerConfig) *circuitBreaker {
+	if config == nil {
+		return nil
+	}
+	b := &circuitBreaker{config: *config, state: CircuitClosed}
+	if b.config.FailureThreshold <= 0 {
+		b.config.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if b.config.OpenTimeout <= 0 {
+		b.config.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if b.config.HalfOpenMaxRequests <= 0 {
+		b.config.HalfOpenMaxRequests = defaultBreakerHalfOpenRequests
+	}
+	return b
+}
+
+// State 返回熔断器当前状态
+func (b *circuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow 判断请求能否发出, probe 表示该请求是半开状态下的探测请求
+func (b *circuitBreaker) allow(ctx context.Context) (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	var transition *CircuitTransition
+	defer func() {
+		b.mu.Unlock()
+		b.emit(ctx, transition)
+	}()
+
+	if b.state == CircuitOpen {
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+		transition = b.setState(CircuitHalfOpen)
+	}
+	if b.state == CircuitHalfOpen {
+		if b.probes >= b.config.HalfOpenMaxRequests {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// record 记录请求结果并驱动状态变化
+func (b *circuitBreaker) record(ctx context.Context, probe bool, err error) {
+	if b == nil {
+		return
+	}
+	outcome := classifyBreakerOutcome(ctx, err)
+	b.mu.Lock()
+	var transition *CircuitTransition
+	defer func() {
+		b.mu.Unlock()
+		b.emit(ctx, transition)
+	}()
+
+	switch b.state {
+	case CircuitClosed:
+		switch outcome {
+		case breakerNeutral:
+			return
+		case breakerSuccess:
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			transition = b.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if !probe {
+			return
+		}
+		switch outcome {
+		case breakerNeutral:
+			// 释放探测名额, 允许后续请求继续探测
+			if b.probes > 0 {
+				b.probes--
+			}
+			return
+		case breakerFailure:
+			transition = b.setState(CircuitOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenMaxRequests {
+			transition = b.setState(CircuitClosed)
+		}
+	}
+}
+
+// setState 需要在持有锁时调用
+func (b *circuitBreaker) setState(state CircuitState) *CircuitTransition {
+	transition := &CircuitTransition{From: b.state, To: state, At: time.Now()}
+	b.state = state
+	b.failures, b.probes, b.successes = 0, 0, 0
+	if state == CircuitOpen {
+		b.openedAt = transition.At
+	}
+	return transition
+}
+
+func (b *circuitBreaker) emit(ctx context.Context, transition *CircuitTransition) {
+	if transition == nil {
+		return
+	}
+	getCallStats(ctx).addCircuitTransition(*transition)
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(*transition)
+	}
+}
+
+func classifyBreakerOutcome(ctx context.Context, err error) breakerOutcome {
+	if err == nil {
+		return breakerSuccess
+	}
+	// 调用方主动取消, 或在等待限流器时失败 (请求未发出), 都不代表服务状态
+	if errors.Is(err, errRateLimitWait) || (ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+		return breakerNeutral
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return breakerSuccess
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode < http.StatusInternalServerError && httpErr.StatusCode != http.StatusTooManyRequests {
+		return breakerSuccess
+	}
+	return breakerFailure
+}
+
+// BreakerState 返回检索请求熔断器的当前状态, 未开启熔断时始终为 CircuitClosed
+func (r *Retriever) BreakerState() CircuitState {
+	return r.breaker.State()
+}
@@ -0,0 +1,147 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type staticRetriever struct {
+	docs []*schema.Document
+}
+
+func (s *staticRetriever) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	return s.docs, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	PatchConvey("test circuit breaker", t, func() {
+		ctx := context.Background()
+		var healthy, hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			if atomic.LoadInt32(&healthy) == 0 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(`{"code":0,"data":{"chunks":[{"id":"1","content":"c","similarity":0.9}]}}`))
+		}))
+		defer server.Close()
+
+		var transitions []CircuitTransition
+		newRetriever := func(fallback retriever.Retriever) *Retriever {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "test",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				CircuitBreaker: &CircuitBreakerConfig{
+					FailureThreshold: 2,
+					OpenTimeout:      50 * time.Millisecond,
+					OnStateChange: func(transition CircuitTransition) {
+						transitions = append(transitions, transition)
+					},
+				},
+				Fallback: fallback,
+			})
+			convey.So(err, convey.ShouldBeNil)
+			return r
+		}
+
+		PatchConvey("test open, half open and close", func() {
+			r := newRetriever(nil)
+			for i := 0; i < 2; i++ {
+				_, err := r.Retrieve(ctx, "q")
+				convey.So(err, convey.ShouldNotBeNil)
+			}
+			convey.So(r.BreakerState(), convey.ShouldEqual, CircuitOpen)
+
+			_, err := r.Retrieve(ctx, "q")
+			convey.So(errors.Is(err, ErrCircuitOpen), convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 2)
+
+			time.Sleep(60 * time.Millisecond)
+			atomic.StoreInt32(&healthy, 1)
+			cbCtx, rec := newCallbackContext(ctx)
+			docs, err := r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 1)
+			convey.So(r.BreakerState(), convey.ShouldEqual, CircuitClosed)
+			convey.So(rec.output.Extra[ExtraKeyCircuitTransitions], convey.ShouldHaveLength, 2)
+			convey.So(transitions, convey.ShouldHaveLength, 3)
+			convey.So(transitions[0].To, convey.ShouldEqual, CircuitOpen)
+			convey.So(transitions[1].To, convey.ShouldEqual, CircuitHalfOpen)
+			convey.So(transitions[2].To, convey.ShouldEqual, CircuitClosed)
+		})
+
+		PatchConvey("test fallback", func() {
+			r := newRetriever(&staticRetriever{docs: []*schema.Document{{ID: "cached"}}})
+			for i := 0; i < 2; i++ {
+				_, _ = r.Retrieve(ctx, "q")
+			}
+			cbCtx, rec := newCallbackContext(ctx)
+			docs, err := r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docs[0].ID, convey.ShouldEqual, "cached")
+			convey.So(rec.output.Extra[ExtraKeyFallback], convey.ShouldBeTrue)
+		})
+
+		PatchConvey("test client errors do not open", func() {
+			b := newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1})
+			b.record(ctx, false, &HTTPError{StatusCode: http.StatusBadRequest})
+			b.record(ctx, false, &APIError{Code: 102})
+			convey.So(b.State(), convey.ShouldEqual, CircuitClosed)
+			b.record(ctx, false, &HTTPError{StatusCode: http.StatusTooManyRequests})
+			convey.So(b.State(), convey.ShouldEqual, CircuitOpen)
+		})
+
+		PatchConvey("test cancelled probe releases the slot", func() {
+			b := newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Millisecond})
+			b.record(ctx, false, &HTTPError{StatusCode: http.StatusBadGateway})
+			time.Sleep(2 * time.Millisecond)
+			probe, err := b.allow(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(probe, convey.ShouldBeTrue)
+			_, err = b.allow(ctx)
+			convey.So(errors.Is(err, ErrCircuitOpen), convey.ShouldBeTrue)
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			b.record(cancelled, probe, context.Canceled)
+			convey.So(b.State(), convey.ShouldEqual, CircuitHalfOpen)
+			probe, err = b.allow(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(probe, convey.ShouldBeTrue)
+			b.record(ctx, probe, nil)
+			convey.So(b.State(), convey.ShouldEqual, CircuitClosed)
+		})
+
+		PatchConvey("test rate limiter wait is not a failure", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:         "test",
+				Endpoint:       server.URL,
+				DatasetIDs:     []string{"kb1"},
+				CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 1},
+				RateLimiter:    NewRateLimiter(RateLimitConfig{QPS: 0.01}),
+			})
+			convey.So(err, convey.ShouldBeNil)
+			atomic.StoreInt32(&healthy, 1)
+			_, err = r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			_, err = r.Retrieve(timeoutCtx, "q")
+			convey.So(errors.Is(err, context.DeadlineExceeded), convey.ShouldBeTrue)
+			convey.So(r.BreakerState(), convey.ShouldEqual, CircuitClosed)
+		})
+	})
+}
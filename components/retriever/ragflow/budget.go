@@ -0,0 +1,154 @@
+package ragflow
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const truncatedTokensKey = "truncated_tokens"
+
+// TokenCounter 计算文本占用的 token 数, 用于 MaxTokens 预算
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// TokenCounterFunc 将普通函数适配为 TokenCounter
+type TokenCounterFunc func(text string) int
+
+func (f TokenCounterFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// HeuristicTokenCounter 是默认的估算方式: 每个中日韩字符计 1 个 token, 其余按单词计, 每 4 个字符约 1 个 token
+type HeuristicTokenCounter struct{}
+
+func (HeuristicTokenCounter) CountTokens(text string) int {
+	tokens, wordLen := 0, 0
+	flush := func() {
+		if wordLen > 0 {
+			tokens += (wordLen + 3) / 4
+			wordLen = 0
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens++
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			flush()
+			tokens++
+		default:
+			wordLen++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// fitTokenBudget 按得分从高到低选出总 token 数不超过 maxTokens 的文档, 保持原有顺序返回.
+// truncate 为 true 时, 第一个放不下的文档会在句子边界截断后放入, 截掉的 token 数记录在 metadata 中
+func fitTokenBudget(docs []*schema.Document, maxTokens int, counter TokenCounter, truncate bool) []*schema.Document {
+	if maxTokens <= 0 || len(docs) == 0 {
+		return docs
+	}
+	if counter == nil {
+		counter = HeuristicTokenCounter{}
+	}
+
+	ranked := make([]int, len(docs))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return docs[ranked[i]].Score() > docs[ranked[j]].Score()
+	})
+
+	keep := make([]bool, len(docs))
+	remaining := maxTokens
+	for _, i := range ranked {
+		doc := docs[i]
+		tokens := counter.CountTokens(doc.Content)
+		if tokens <= remaining {
+			keep[i] = true
+			remaining -= tokens
+			continue
+		}
+		if !truncate {
+			continue
+		}
+		content, ok := truncateAtSentence(doc.Content, remaining, counter)
+		if !ok {
+			// 放不下任何完整句子时跳过该文档, 后面更短的文档仍可能放得下
+			continue
+		}
+		doc.Content = content
+		doc.MetaData[truncatedTokensKey] = tokens - counter.CountTokens(content)
+		if spans := GetHighlights(doc); len(spans) > 0 {
+			kept := make([]HighlightSpan, 0, len(spans))
+			for _, s := range spans {
+				if s.End <= len(content) {
+					kept = append(kept, s)
+				}
+			}
+			setHighlights(doc, kept)
+		}
+		keep[i] = true
+		break
+	}
+
+	result := make([]*schema.Document, 0, len(docs))
+	for i, doc := range docs {
+		if keep[i] {
+			result = append(result, doc)
+		}
+	}
+	return result
+}
+
+// truncateAtSentence 返回 token 数不超过 maxTokens 的最长完整句子前缀
+func truncateAtSentence(content string, maxTokens int, counter TokenCounter) (string, bool) {
+	if maxTokens <= 0 {
+		return "", false
+	}
+	var ends []int
+	for i, r := range content {
+		if isSentenceEnd(r) {
+			ends = append(ends, i+utf8.RuneLen(r))
+		}
+	}
+	// 二分查找最后一个放得下的句子边界
+	n := sort.Search(len(ends), func(k int) bool {
+		return counter.CountTokens(content[:ends[k]]) > maxTokens
+	})
+	if n == 0 {
+		return "", false
+	}
+	return strings.TrimRightFunc(content[:ends[n-1]], unicode.IsSpace), true
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', ';', '\n', '。', '！', '？', '；', '…':
+		return true
+	default:
+		return false
+	}
+}
+
+// GetTruncatedTokens 返回文档因 MaxTokens 预算被截掉的 token 数
+func GetTruncatedTokens(doc *schema.Document) int {
+	if doc == nil {
+		return 0
+	}
+	if v, ok := doc.MetaData[truncatedTokensKey]; ok {
+		return v.(int)
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package ragflow
+
+import (
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFitTokenBudget(t *testing.T) {
+	PatchConvey("test fitTokenBudget", t, func() {
+		wordCounter := TokenCounterFunc(func(text string) int {
+			return len([]rune(text))
+		})
+		newDocs := func() []*schema.Document {
+			return []*schema.Document{
+				(&schema.Document{ID: "1", Content: "aaaa. bbbb.", MetaData: map[string]any{}}).WithScore(0.5),
+				(&schema.Document{ID: "2", Content: "cccccc", MetaData: map[string]any{}}).WithScore(0.9),
+				(&schema.Document{ID: "3", Content: "dd", MetaData: map[string]any{}}).WithScore(0.1),
+			}
+		}
+
+		PatchConvey("test heuristic counter", func() {
+			convey.So(HeuristicTokenCounter{}.CountTokens("hello world"), convey.ShouldEqual, 4)
+			convey.So(HeuristicTokenCounter{}.CountTokens("检索增强 RAG"), convey.ShouldEqual, 5)
+		})
+
+		PatchConvey("test without truncate", func() {
+			docs := fitTokenBudget(newDocs(), 9, wordCounter, false)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+			convey.So(docs[0].ID, convey.ShouldEqual, "2")
+			convey.So(docs[1].ID, convey.ShouldEqual, "3")
+		})
+
+		PatchConvey("test with truncate", func() {
+			docs := fitTokenBudget(newDocs(), 12, wordCounter, true)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+			convey.So(docs[0].ID, convey.ShouldEqual, "1")
+			convey.So(docs[0].Content, convey.ShouldEqual, "aaaa.")
+			convey.So(GetTruncatedTokens(docs[0]), convey.ShouldEqual, 6)
+			convey.So(docs[1].ID, convey.ShouldEqual, "2")
+		})
+
+		PatchConvey("test truncate skips documents without a fitting sentence", func() {
+			docs := fitTokenBudget(newDocs(), 9, wordCounter, true)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+			convey.So(docs[0].ID, convey.ShouldEqual, "2")
+			convey.So(docs[1].ID, convey.ShouldEqual, "3")
+		})
+
+		PatchConvey("test unlimited", func() {
+			convey.So(len(fitTokenBudget(newDocs(), 0, nil, true)), convey.ShouldEqual, 3)
+		})
+	})
+}
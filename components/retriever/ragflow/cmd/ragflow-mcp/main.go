@@ -0,0 +1,339 @@
+// ragflow-mcp 以 Model Context Protocol 工具的形式通过 stdio 提供 RAGFlow 知识库检索, 供 IDE 助手等 MCP 客户端使用.
+//
+// 配置可以通过命令行参数或同名环境变量提供, 命令行参数优先, 例如:
+//
+//	RAGFLOW_ENDPOINT=http://localhost:9380 RAGFLOW_API_KEY=ragflow-xxx RAGFLOW_DATASET_IDS=id1,id2 ragflow-mcp
+//
+// API Key 只能通过环境变量 RAGFLOW_API_KEY 或 -api-key-file 提供, 避免出现在进程参数中.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/Abei1uo/eino-ext/components/retriever/ragflow"
+)
+
+const (
+	serverName    = "ragflow-mcp"
+	serverVersion = "0.1.0"
+
+	datasetsURI = "ragflow://datasets"
+)
+
+// envNames 是命令行参数对应的环境变量, 命令行未指定时使用环境变量的值
+var envNames = map[string]string{
+	"endpoint":                 "RAGFLOW_ENDPOINT",
+	"api-key-file":             "RAGFLOW_API_KEY_FILE",
+	"dataset-ids":              "RAGFLOW_DATASET_IDS",
+	"dataset-names":            "RAGFLOW_DATASET_NAMES",
+	"document-ids":             "RAGFLOW_DOCUMENT_IDS",
+	"timeout":                  "RAGFLOW_TIMEOUT",
+	"similarity-threshold":     "RAGFLOW_SIMILARITY_THRESHOLD",
+	"vector-similarity-weight": "RAGFLOW_VECTOR_SIMILARITY_WEIGHT",
+	"rerank-id":                "RAGFLOW_RERANK_ID",
+	"keyword":                  "RAGFLOW_KEYWORD",
+	"highlight":                "RAGFLOW_HIGHLIGHT",
+	"content-format":           "RAGFLOW_CONTENT_FORMAT",
+	"max-tokens":               "RAGFLOW_MAX_TOKENS",
+	"max-top-k":                "RAGFLOW_MAX_TOP_K",
+	"tool-name":                "RAGFLOW_TOOL_NAME",
+	"tool-description":         "RAGFLOW_TOOL_DESCRIPTION",
+}
+
+// options 是命令的配置
+type options struct {
+	endpoint               string
+	apiKeyFile             string
+	datasetIDs             string
+	datasetNames           string
+	documentIDs            string
+	timeout                int
+	similarityThreshold    float64
+	vectorSimilarityWeight float64
+	rerankID               string
+	keyword                bool
+	highlight              bool
+	contentFormat          string
+	maxTokens              int
+	maxTopK                int
+	toolName               string
+	toolDescription        string
+}
+
+// parseOptions 解析命令行参数, 未指定的参数回退到 getenv 返回的环境变量
+func parseOptions(args []string, getenv func(string) string, output io.Writer) (*options, error) {
+	o := &options{}
+	fs := flag.NewFlagSet(serverName, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&o.endpoint, "endpoint", "", "RAGFlow endpoint, defaults to https://ragflow.io")
+	fs.StringVar(&o.apiKeyFile, "api-key-file", "", "file containing the API key, takes precedence over RAGFLOW_API_KEY")
+	fs.StringVar(&o.datasetIDs, "dataset-ids", "", "comma-separated dataset IDs to search")
+	fs.StringVar(&o.datasetNames, "dataset-names", "", "comma-separated dataset names to search")
+	fs.StringVar(&o.documentIDs, "document-ids", "", "comma-separated document IDs to search")
+	fs.IntVar(&o.timeout, "timeout", 0, "HTTP timeout in seconds, 0 means no timeout")
+	fs.Float64Var(&o.similarityThreshold, "similarity-threshold", 0, "default minimum similarity score, 0 uses the RAGFlow default")
+	fs.Float64Var(&o.vectorSimilarityWeight, "vector-similarity-weight", 0, "weight of vector cosine similarity, 0 uses the RAGFlow default")
+	fs.StringVar(&o.rerankID, "rerank-id", "", "RAGFlow rerank model ID")
+	fs.BoolVar(&o.keyword, "keyword", false, "enable keyword-based matching")
+	fs.BoolVar(&o.highlight, "highlight", false, "enable highlighting of matched terms")
+	fs.StringVar(&o.contentFormat, "content-format", "", "default content format: raw, plain or markdown")
+	fs.IntVar(&o.maxTokens, "max-tokens", 0, "default token budget of the results, 0 means unlimited")
+	fs.IntVar(&o.maxTopK, "max-top-k", 10, "upper bound of the top_k tool argument, also used when top_k is omitted")
+	fs.StringVar(&o.toolName, "tool-name", "search_knowledge_base", "name of the MCP tool")
+	fs.StringVar(&o.toolDescription, "tool-description", "", "description of the MCP tool")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, env := range envNames {
+		if value := getenv(env); value != "" && !set[name] {
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+
+	if o.datasetIDs == "" && o.datasetNames == "" && o.documentIDs == "" {
+		return nil, fmt.Errorf("one of -dataset-ids, -dataset-names or -document-ids is required")
+	}
+	if o.maxTopK <= 0 {
+		return nil, fmt.Errorf("max-top-k must be positive")
+	}
+	if _, err := parseContentFormat(o.contentFormat); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// retrieverConfig 将命令配置转换为 RetrieverConfig
+func (o *options) retrieverConfig() *ragflow.RetrieverConfig {
+	var credentials ragflow.CredentialProvider = ragflow.EnvCredential("RAGFLOW_API_KEY")
+	if o.apiKeyFile != "" {
+		credentials = ragflow.NewFileCredential(o.apiKeyFile)
+	}
+	reqOption := &ragflow.RetrievalRequestOption{
+		RerankID:  o.rerankID,
+		Keyword:   o.keyword,
+		Highlight: o.highlight,
+	}
+	if o.similarityThreshold > 0 {
+		reqOption.SimilarityThreshold = &o.similarityThreshold
+	}
+	if o.vectorSimilarityWeight > 0 {
+		reqOption.VectorSimilarityWeight = &o.vectorSimilarityWeight
+	}
+	format, _ := parseContentFormat(o.contentFormat)
+	return &ragflow.RetrieverConfig{
+		Endpoint:               o.endpoint,
+		CredentialProvider:     credentials,
+		DatasetIDs:             splitList(o.datasetIDs),
+		DatasetNames:           splitList(o.datasetNames),
+		DocumentIDs:            splitList(o.documentIDs),
+		LazyResolveNames:       true,
+		RetrievalRequestOption: reqOption,
+		// RetrieverConfig.Timeout 以秒为单位
+		Timeout:       time.Duration(o.timeout),
+		ContentFormat: format,
+		MaxTokens:     o.maxTokens,
+	}
+}
+
+// newServer 创建 MCP server, 注册检索工具与数据集列表资源
+func newServer(r *ragflow.Retriever, o *options) *server.MCPServer {
+	s := server.NewMCPServer(serverName, serverVersion,
+		server.WithToolCapabilities(false),
+		server.WithResourceCapabilities(false, false),
+	)
+	s.AddTool(searchTool(o), searchHandler(r, o))
+	s.AddResource(mcp.NewResource(datasetsURI, "RAGFlow datasets",
+		mcp.WithResourceDescription("Datasets this server searches, as a JSON array. Use their IDs as dataset_ids."),
+		mcp.WithMIMEType("application/json"),
+	), datasetsHandler(r))
+	return s
+}
+
+// searchTool 定义检索工具, 参数对应 Retriever 支持的单次调用选项
+func searchTool(o *options) mcp.Tool {
+	desc := o.toolDescription
+	if desc == "" {
+		desc = "Search the knowledge base for passages relevant to a question. " +
+			"Use it when the answer may depend on internal documents. " +
+			fmt.Sprintf("Available datasets are listed in the %s resource. ", datasetsURI) +
+			"Results are numbered passages; cite them as [n] in your answer."
+	}
+	return mcp.NewTool(o.toolName,
+		mcp.WithDescription(desc),
+		mcp.WithString("query", mcp.Required(),
+			mcp.Description("The search query. Rewrite the user question into a concise and self-contained query.")),
+		mcp.WithNumber("top_k",
+			mcp.Description(fmt.Sprintf("Maximum number of passages to return. At most %d, defaults to %d.", o.maxTopK, o.maxTopK)),
+			mcp.Min(1), mcp.Max(float64(o.maxTopK))),
+		mcp.WithArray("dataset_ids",
+			mcp.Description("Restrict the search to these dataset IDs. Omit to search all configured datasets."),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("similarity_threshold",
+			mcp.Description("Minimum similarity score between 0 and 1."),
+			mcp.Min(0), mcp.Max(1)),
+		mcp.WithString("content_format",
+			mcp.Description("Format of the passage content."),
+			mcp.Enum("raw", "plain", "markdown")),
+		mcp.WithNumber("max_tokens",
+			mcp.Description("Total token budget of the returned passages. 0 means unlimited."),
+			mcp.Min(0)),
+		mcp.WithNumber("context_chunks",
+			mcp.Description("Number of neighbouring chunks to include before and after each hit."),
+			mcp.Min(0)),
+		mcp.WithBoolean("collapse_documents",
+			mcp.Description("Merge hits from the same document into a single passage.")),
+	)
+}
+
+func searchHandler(r *ragflow.Retriever, o *options) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := req.RequireString("query")
+		if err != nil || strings.TrimSpace(query) == "" {
+			return mcp.NewToolResultError("query is required"), nil
+		}
+		opts, topK, err := callOptions(req, o.maxTopK)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("invalid arguments", err), nil
+		}
+		docs, err := r.Retrieve(ctx, query, opts...)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("search failed", err), nil
+		}
+		if len(docs) > topK {
+			docs = docs[:topK]
+		}
+		return mcp.NewToolResultText(ragflow.FormatCitations(docs)), nil
+	}
+}
+
+// callOptions 将工具参数转换为 Retrieve 的单次调用选项, 未提供的参数沿用命令配置
+func callOptions(req mcp.CallToolRequest, maxTopK int) ([]retriever.Option, int, error) {
+	topK := req.GetInt("top_k", 0)
+	if topK <= 0 || topK > maxTopK {
+		topK = maxTopK
+	}
+	// top_k 是返回的段落数, 对应 RAGFlow 的 page_size, 不改变 RAGFlow 参与向量计算的候选数
+	opts := []retriever.Option{ragflow.WithPageSize(topK)}
+
+	args := req.GetArguments()
+	if ids := req.GetStringSlice("dataset_ids", nil); len(ids) > 0 {
+		opts = append(opts, ragflow.WithDatasetIDs(ids...))
+	}
+	if _, ok := args["similarity_threshold"]; ok {
+		threshold := req.GetFloat("similarity_threshold", 0)
+		if threshold < 0 || threshold > 1 {
+			return nil, 0, fmt.Errorf("similarity_threshold must be between 0 and 1")
+		}
+		opts = append(opts, retriever.WithScoreThreshold(threshold))
+	}
+	if value := req.GetString("content_format", ""); value != "" {
+		format, err := parseContentFormat(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		opts = append(opts, ragflow.WithContentFormat(format))
+	}
+	if _, ok := args["max_tokens"]; ok {
+		opts = append(opts, ragflow.WithMaxTokens(max(req.GetInt("max_tokens", 0), 0)))
+	}
+	if n := req.GetInt("context_chunks", 0); n > 0 {
+		opts = append(opts, ragflow.WithContextExpansion(&ragflow.ContextExpansion{Before: n, After: n}))
+	}
+	if req.GetBool("collapse_documents", false) {
+		opts = append(opts, ragflow.WithDocumentCollapse(&ragflow.DocumentCollapse{}))
+	}
+	return opts, topK, nil
+}
+
+// datasetsHandler 列出本服务检索的数据集; 只有这些数据集可以作为 dataset_ids, 未限定数据集时列出 API Key 可访问的全部数据集
+func datasetsHandler(r *ragflow.Retriever) server.ResourceHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		configured, err := r.ConfiguredDatasetIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all, err := r.ListDatasets(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		allowed := make(map[string]bool, len(configured))
+		for _, id := range configured {
+			allowed[id] = true
+		}
+		datasets := make([]ragflow.Dataset, 0, len(all))
+		for _, d := range all {
+			if len(allowed) == 0 || allowed[d.ID] {
+				datasets = append(datasets, d)
+			}
+		}
+		data, err := json.Marshal(datasets)
+		if err != nil {
+			return nil, fmt.Errorf("marshal datasets failed: %w", err)
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      req.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(data),
+		}}, nil
+	}
+}
+
+func parseContentFormat(value string) (ragflow.ContentFormat, error) {
+	switch value {
+	case "", "raw":
+		return ragflow.ContentFormatRaw, nil
+	case "plain":
+		return ragflow.ContentFormatPlain, nil
+	case "markdown":
+		return ragflow.ContentFormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("unknown content format %q", value)
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func main() {
+	// stdout 用于 MCP 协议消息, 日志只能写到 stderr
+	log.SetOutput(os.Stderr)
+	o, err := parseOptions(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	r, err := ragflow.NewRetriever(ctx, o.retrieverConfig())
+	if err != nil {
+		log.Fatalf("create retriever failed: %v", err)
+	}
+	if err = server.ServeStdio(newServer(r, o)); err != nil {
+		log.Fatal(err)
+	}
+}
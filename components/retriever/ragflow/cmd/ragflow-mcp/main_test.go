@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/Abei1uo/eino-ext/components/retriever/ragflow"
+)
+
+// fakeRAGFlow 模拟 RAGFlow 的检索与数据集列表接口
+type fakeRAGFlow struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+func newFakeRAGFlow() *fakeRAGFlow {
+	f := &fakeRAGFlow{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer test-key" {
+			_, _ = w.Write([]byte(`{"code":109,"message":"Authentication error: API key is invalid!","data":false}`))
+			return
+		}
+		switch req.URL.Path {
+		case "/api/v1/retrieval":
+			body := map[string]any{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			f.mu.Lock()
+			f.requests = append(f.requests, body)
+			f.mu.Unlock()
+			_, _ = w.Write([]byte(`{"code":0,"data":{"chunks":[` +
+				`{"id":"c1","content":"RAGFlow supports MCP.","document_id":"d1","document_keyword":"guide.md","kb_id":"ds2","similarity":0.9},` +
+				`{"id":"c2","content":"Chunks are ranked by similarity.","document_id":"d2","document_keyword":"faq.md","kb_id":"ds2","similarity":0.8}` +
+				`],"doc_aggs":[],"total":2}}`))
+		case "/api/v1/datasets":
+			_, _ = w.Write([]byte(`{"code":0,"data":[{"id":"ds1","name":"guides","chunk_count":10,"document_count":2},{"id":"ds2","name":"faq","chunk_count":5,"document_count":1},{"id":"ds3","name":"finance","chunk_count":7,"document_count":3}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return f
+}
+
+func (f *fakeRAGFlow) lastRequest() map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return nil
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestParseOptions(t *testing.T) {
+	PatchConvey("test parseOptions", t, func() {
+		Convey("test env fallback", func() {
+			o, err := parseOptions(nil, env(map[string]string{
+				"RAGFLOW_ENDPOINT":             "http://ragflow:9380",
+				"RAGFLOW_DATASET_IDS":          "ds1, ds2",
+				"RAGFLOW_SIMILARITY_THRESHOLD": "0.3",
+				"RAGFLOW_KEYWORD":              "true",
+			}), io.Discard)
+			So(err, ShouldBeNil)
+			config := o.retrieverConfig()
+			So(config.Endpoint, ShouldEqual, "http://ragflow:9380")
+			So(config.DatasetIDs, ShouldResemble, []string{"ds1", "ds2"})
+			So(*config.RetrievalRequestOption.SimilarityThreshold, ShouldEqual, 0.3)
+			So(config.RetrievalRequestOption.Keyword, ShouldBeTrue)
+			So(config.CredentialProvider, ShouldEqual, ragflow.EnvCredential("RAGFLOW_API_KEY"))
+		})
+
+		Convey("test flags take precedence over env", func() {
+			o, err := parseOptions([]string{"-dataset-names", "faq", "-max-top-k", "3", "-content-format", "markdown"},
+				env(map[string]string{"RAGFLOW_DATASET_NAMES": "guides", "RAGFLOW_MAX_TOP_K": "20"}), io.Discard)
+			So(err, ShouldBeNil)
+			So(o.maxTopK, ShouldEqual, 3)
+			config := o.retrieverConfig()
+			So(config.DatasetNames, ShouldResemble, []string{"faq"})
+			So(config.ContentFormat, ShouldEqual, ragflow.ContentFormatMarkdown)
+		})
+
+		Convey("test invalid options", func() {
+			_, err := parseOptions(nil, env(nil), io.Discard)
+			So(err, ShouldNotBeNil)
+			_, err = parseOptions(nil, env(map[string]string{"RAGFLOW_DATASET_IDS": "ds1", "RAGFLOW_TIMEOUT": "abc"}), io.Discard)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "RAGFLOW_TIMEOUT")
+			_, err = parseOptions([]string{"-dataset-ids", "ds1", "-content-format", "html"}, env(nil), io.Discard)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Setenv("RAGFLOW_API_KEY", "test-key")
+	fake := newFakeRAGFlow()
+	defer fake.Close()
+
+	ctx := context.Background()
+	o, err := parseOptions([]string{"-endpoint", fake.URL, "-dataset-ids", "ds1,ds2", "-max-top-k", "5"}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ragflow.NewRetriever(ctx, o.retrieverConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(r, o)
+	call := func(method string, params any) map[string]any {
+		raw, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		data, _ := json.Marshal(s.HandleMessage(ctx, raw))
+		resp := map[string]any{}
+		_ = json.Unmarshal(data, &resp)
+		return resp
+	}
+
+	PatchConvey("test mcp server", t, func() {
+		Convey("test tools/list", func() {
+			resp := call("tools/list", map[string]any{})
+			tools := resp["result"].(map[string]any)["tools"].([]any)
+			So(len(tools), ShouldEqual, 1)
+			tool := tools[0].(map[string]any)
+			So(tool["name"], ShouldEqual, "search_knowledge_base")
+			props := tool["inputSchema"].(map[string]any)["properties"].(map[string]any)
+			for _, name := range []string{"query", "top_k", "dataset_ids", "similarity_threshold", "content_format", "max_tokens", "context_chunks", "collapse_documents"} {
+				So(props, ShouldContainKey, name)
+			}
+		})
+
+		Convey("test tools/call maps arguments to retrieve options", func() {
+			resp := call("tools/call", map[string]any{
+				"name": "search_knowledge_base",
+				"arguments": map[string]any{
+					"query":                "does ragflow support mcp",
+					"top_k":                1,
+					"dataset_ids":          []string{"ds2"},
+					"similarity_threshold": 0.4,
+				},
+			})
+			result := resp["result"].(map[string]any)
+			So(result["isError"], ShouldBeNil)
+			text := result["content"].([]any)[0].(map[string]any)["text"].(string)
+			So(text, ShouldStartWith, "[1] citation_id: c1")
+			So(text, ShouldNotContainSubstring, "[2]")
+
+			req := fake.lastRequest()
+			So(req["question"], ShouldEqual, "does ragflow support mcp")
+			So(req["dataset_ids"], ShouldResemble, []any{"ds2"})
+			So(req["similarity_threshold"], ShouldEqual, 0.4)
+			So(req["page_size"], ShouldEqual, 1)
+			So(req, ShouldNotContainKey, "top_k")
+		})
+
+		Convey("test tools/call with invalid arguments", func() {
+			resp := call("tools/call", map[string]any{
+				"name":      "search_knowledge_base",
+				"arguments": map[string]any{"query": "mcp", "content_format": "html"},
+			})
+			So(resp["result"].(map[string]any)["isError"], ShouldBeTrue)
+
+			resp = call("tools/call", map[string]any{
+				"name":      "search_knowledge_base",
+				"arguments": map[string]any{"query": " "},
+			})
+			So(resp["result"].(map[string]any)["isError"], ShouldBeTrue)
+		})
+
+		Convey("test resources/read lists configured datasets", func() {
+			resp := call("resources/read", map[string]any{"uri": datasetsURI})
+			contents := resp["result"].(map[string]any)["contents"].([]any)
+			So(len(contents), ShouldEqual, 1)
+			content := contents[0].(map[string]any)
+			So(content["mimeType"], ShouldEqual, "application/json")
+			var datasets []ragflow.Dataset
+			So(json.Unmarshal([]byte(content["text"].(string)), &datasets), ShouldBeNil)
+			So(len(datasets), ShouldEqual, 2)
+			So(datasets[1].Name, ShouldEqual, "faq")
+		})
+	})
+}
@@ -0,0 +1,225 @@
+package ragflow
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	chunkIDsKey  = "chunk_ids"
+	positionsKey = "positions"
+
+	defaultCollapseTopChunks = 3
+	defaultCollapseSeparator = "\n"
+)
+
+// CollapseScore 决定折叠后文档得分的计算方式
+type CollapseScore string
+
+const (
+	// CollapseScoreMax 取文档内 chunk 的最高分 (默认)
+	CollapseScoreMax CollapseScore = ""
+	// CollapseScoreSum 取文档内 chunk 得分之和
+	CollapseScoreSum CollapseScore = "sum"
+	// CollapseScoreCountWeighted 取最高分乘以 DocAggs 中命中数与最大命中数之比, 命中越多的文档得分越高
+	CollapseScoreCountWeighted CollapseScore = "count_weighted"
+)
+
+func (s CollapseScore) valid() bool {
+	switch s {
+	case CollapseScoreMax, CollapseScoreSum, CollapseScoreCountWeighted:
+		return true
+	default:
+		return false
+	}
+}
+
+// DocumentCollapse 定义了按源文档折叠 chunk 结果的参数
+type DocumentCollapse struct {
+	// Score 文档得分的计算方式, 默认取最高分
+	Score CollapseScore
+	// TopChunks 每个文档保留得分最高的 chunk 数, 按文档内位置顺序拼接, 默认 3
+	TopChunks int
+	// Separator chunk 之间的分隔符, 默认为换行
+	Separator string
+}
+
+// collapseDocuments 将 chunk 按 DocumentID 聚合为文档级结果, 按文档得分降序返回
+func collapseDocuments(docs []*schema.Document, aggs []DocAgg, c *DocumentCollapse) []*schema.Document {
+	if c == nil || len(docs) == 0 {
+		return docs
+	}
+	topChunks := c.TopChunks
+	if topChunks <= 0 {
+		topChunks = defaultCollapseTopChunks
+	}
+	sep := c.Separator
+	if sep == "" {
+		sep = defaultCollapseSeparator
+	}
+
+	groups := make(map[string][]*schema.Document)
+	order := make([]string, 0)
+	for _, doc := range docs {
+		id := GetOrgDocID(doc)
+		if _, ok := groups[id]; !ok {
+			order = append(order, id)
+		}
+		groups[id] = append(groups[id], doc)
+	}
+	counts := make(map[string]int64, len(aggs))
+	var maxCount int64
+	for _, agg := range aggs {
+		counts[agg.DocID] = agg.Count
+		maxCount = max(maxCount, agg.Count)
+	}
+
+	result := make([]*schema.Document, 0, len(order))
+	for _, id := range order {
+		chunks := groups[id]
+		sort.SliceStable(chunks, func(i, j int) bool {
+			return chunks[i].Score() > chunks[j].Score()
+		})
+
+		var score float64
+		switch c.Score {
+		case CollapseScoreSum:
+			for _, chunk := range chunks {
+				score += chunk.Score()
+			}
+		case CollapseScoreCountWeighted:
+			// 缺少 DocAggs 的文档不按命中数加权, 不能影响其他文档使用的最大命中数
+			count, ok := counts[id]
+			denom := maxCount
+			if !ok || denom == 0 {
+				count, denom = 1, 1
+			}
+			score = chunks[0].Score() * float64(count) / float64(denom)
+		default:
+			score = chunks[0].Score()
+		}
+
+		chunkIDs := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			chunkIDs = append(chunkIDs, GetChunkID(chunk))
+		}
+		selected := append([]*schema.Document(nil), chunks[:min(topChunks, len(chunks))]...)
+		sort.SliceStable(selected, func(i, j int) bool {
+			return positionLess(GetPositions(selected[i]), GetPositions(selected[j]))
+		})
+
+		doc := &schema.Document{
+			ID:       id,
+			MetaData: map[string]any{},
+		}
+		var sb strings.Builder
+		var spans []HighlightSpan
+		var keywords []string
+		for i, chunk := range selected {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			offset := sb.Len()
+			for _, s := range GetHighlights(chunk) {
+				spans = append(spans, HighlightSpan{Start: s.Start + offset, End: s.End + offset, Text: s.Text})
+			}
+			sb.WriteString(chunk.Content)
+			keywords = append(keywords, GetKeywords(chunk)...)
+		}
+		doc.Content = sb.String()
+		doc.WithScore(score)
+		setOrgDocID(doc, id)
+		setOrgDocName(doc, GetOrgDocName(chunks[0]))
+		setDatasetID(doc, GetDatasetID(chunks[0]))
+		setKeywords(doc, keywords)
+		setChunkIDs(doc, chunkIDs)
+		if spans != nil {
+			setHighlights(doc, spans)
+		}
+		result = append(result, doc)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score() > result[j].Score()
+	})
+	return result
+}
+
+// positionLess 按 RAGFlow 位置信息 [page, left, right, top, bottom] 比较 chunk 在文档中的先后, 无法解析时保持原顺序
+func positionLess(a, b []string) bool {
+	pa, oka := parsePosition(a)
+	pb, okb := parsePosition(b)
+	if !oka || !okb {
+		return false
+	}
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return pa[i] < pb[i]
+		}
+	}
+	return false
+}
+
+func parsePosition(positions []string) ([2]int, bool) {
+	if len(positions) == 0 {
+		return [2]int{}, false
+	}
+	fields := strings.FieldsFunc(positions[0], func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '-'
+	})
+	if len(fields) == 0 {
+		return [2]int{}, false
+	}
+	var nums []int
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return [2]int{}, false
+		}
+		nums = append(nums, n)
+	}
+	pos := [2]int{nums[0]}
+	if len(nums) >= 4 {
+		pos[1] = nums[3]
+	}
+	return pos, true
+}
+
+func setPositions(doc *schema.Document, positions []string) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[positionsKey] = positions
+}
+
+func setChunkIDs(doc *schema.Document, ids []string) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[chunkIDsKey] = ids
+}
+
+func GetPositions(doc *schema.Document) []string {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[positionsKey]; ok {
+		return v.([]string)
+	}
+	return nil
+}
+
+// GetChunkIDs 返回折叠后文档包含的全部 chunk ID, 按得分降序
+func GetChunkIDs(doc *schema.Document) []string {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[chunkIDsKey]; ok {
+		return v.([]string)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package ragflow
+
+import (
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollapseDocuments(t *testing.T) {
+	PatchConvey("test collapseDocuments", t, func() {
+		chunks := []Chunk{
+			{ID: "a2", DocumentID: "A", Content: "a2", Similarity: 0.9, Positions: []string{"[2, 0, 0, 10, 20]"}},
+			{ID: "b1", DocumentID: "B", Content: "b1", Similarity: 0.8},
+			{ID: "a1", DocumentID: "A", Content: "a1", Similarity: 0.5, Positions: []string{"[1, 0, 0, 50, 60]"}},
+			{ID: "a3", DocumentID: "A", Content: "a3", Similarity: 0.1, Positions: []string{"[1, 0, 0, 10, 20]"}},
+			{ID: "b2", DocumentID: "B", Content: "b2", Similarity: 0.75},
+		}
+		docs := make([]*schema.Document, 0, len(chunks))
+		for i := range chunks {
+			docs = append(docs, chunks[i].toDoc(&implOptions{}))
+		}
+		aggs := []DocAgg{{DocID: "A", Count: 3}, {DocID: "B", Count: 6}}
+
+		PatchConvey("test max score", func() {
+			res := collapseDocuments(docs, aggs, &DocumentCollapse{TopChunks: 2})
+			convey.So(len(res), convey.ShouldEqual, 2)
+			convey.So(res[0].ID, convey.ShouldEqual, "A")
+			convey.So(res[0].Score(), convey.ShouldEqual, 0.9)
+			convey.So(res[0].Content, convey.ShouldEqual, "a1\na2")
+			convey.So(GetChunkIDs(res[0]), convey.ShouldResemble, []string{"a2", "a1", "a3"})
+		})
+
+		PatchConvey("test sum score", func() {
+			res := collapseDocuments(docs, aggs, &DocumentCollapse{Score: CollapseScoreSum, TopChunks: 3})
+			convey.So(res[0].ID, convey.ShouldEqual, "B")
+			convey.So(res[0].Score(), convey.ShouldAlmostEqual, 1.55)
+			convey.So(res[1].Content, convey.ShouldEqual, "a3\na1\na2")
+		})
+
+		PatchConvey("test count weighted score", func() {
+			res := collapseDocuments(docs, aggs, &DocumentCollapse{Score: CollapseScoreCountWeighted})
+			convey.So(res[0].ID, convey.ShouldEqual, "B")
+			convey.So(res[0].Score(), convey.ShouldAlmostEqual, 0.8)
+			convey.So(res[1].Score(), convey.ShouldAlmostEqual, 0.45)
+		})
+
+		PatchConvey("test count weighted score with missing doc agg", func() {
+			chunks := []Chunk{
+				{ID: "x1", DocumentID: "X", Content: "x1", Similarity: 0.5},
+				{ID: "a1", DocumentID: "A", Content: "a1", Similarity: 0.9},
+				{ID: "b1", DocumentID: "B", Content: "b1", Similarity: 0.9},
+			}
+			res := collapseDocuments(toDocs(chunks), []DocAgg{{DocID: "A", Count: 10}, {DocID: "B", Count: 1}},
+				&DocumentCollapse{Score: CollapseScoreCountWeighted})
+			convey.So(docIDs(res), convey.ShouldResemble, []string{"A", "X", "B"})
+			convey.So(res[0].Score(), convey.ShouldAlmostEqual, 0.9)
+			convey.So(res[1].Score(), convey.ShouldAlmostEqual, 0.5)
+			convey.So(res[2].Score(), convey.ShouldAlmostEqual, 0.09)
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// CredentialProvider 在每次请求前提供 API Key, refresh 为 true 表示上一次使用的 Key 认证失败, 需要绕过缓存重新获取
+type CredentialProvider interface {
+	GetAPIKey(ctx context.Context, refresh bool) (string, error)
+}
+
+// CredentialProviderFunc 将普通函数适配为 CredentialProvider
+type CredentialProviderFunc func(ctx context.Context, refresh bool) (string, error)
+
+func (f CredentialProviderFunc) GetAPIKey(ctx context.Context, refresh bool) (string, error) {
+	return f(ctx, refresh)
+}
+
+// StaticCredential 始终返回固定的 API Key
+type StaticCredential string
+
+func (c StaticCredential) GetAPIKey(ctx context.Context, refresh bool) (string, error) {
+	if c == "" {
+		return "", fmt.Errorf("api_key is empty")
+	}
+	return string(c), nil
+}
+
+// EnvCredential 每次请求时从环境变量读取 API Key
+type EnvCredential string
+
+func (c EnvCredential) GetAPIKey(ctx context.Context, refresh bool) (string, error) {
+	key := strings.TrimSpace(os.Getenv(string(c)))
+	if key == "" {
+		return "", fmt.Errorf("environment variable %s is empty", string(c))
+	}
+	return key, nil
+}
+
+// FileCredential 从文件读取 API Key, 文件修改时间变化后自动重新读取, 适用于挂载的 Secret
+type FileCredential struct {
+	path string
+
+	mu      sync.Mutex
+	key     string
+	modTime time.Time
+}
+
+func NewFileCredential(path string) *FileCredential {
+	return &FileCredential{path: path}
+}
+
+func (c *FileCredential) GetAPIKey(ctx context.Context, refresh bool) (string, error) {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return "", fmt.Errorf("stat credential file failed: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !refresh && c.key != "" && info.ModTime().Equal(c.modTime) {
+		return c.key, nil
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", fmt.Errorf("read credential file failed: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("credential file %s is empty", c.path)
+	}
+	c.key, c.modTime = key, info.ModTime()
+	return key, nil
+}
+
+// cachedCredential 在 ttl 内复用上一次获取的 API Key
+type cachedCredential struct {
+	provider CredentialProvider
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	key       string
+	fetchedAt time.Time
+}
+
+// NewCachedCredential 为获取代价较高的 CredentialProvider (例如远程密钥服务) 增加缓存, refresh 时绕过缓存
+func NewCachedCredential(provider CredentialProvider, ttl time.Duration) CredentialProvider {
+	return &cachedCredential{provider: provider, ttl: ttl}
+}
+
+func (c *cachedCredential) GetAPIKey(ctx context.Context, refresh bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !refresh && c.key != "" && time.Since(c.fetchedAt) < c.ttl {
+		return c.key, nil
+	}
+	key, err := c.provider.GetAPIKey(ctx, refresh)
+	if err != nil {
+		return "", err
+	}
+	c.key, c.fetchedAt = key, time.Now()
+	return key, nil
+}
+
+type apiKeyOverrideKey struct{}
+
+func withAPIKeyOverride(ctx context.Context, apiKey string) context.Context {
+	if apiKey == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, apiKeyOverrideKey{}, apiKey)
+}
+
+// getAuthorization 返回本次请求的 Authorization 头, refreshable 表示认证失败后能否刷新重试.
+// 优先级: 单次调用覆盖的 Key > CredentialProvider > RetrieverConfig.APIKey
+func (r *Retriever) getAuthorization(ctx context.Context, refresh bool) (authorization string, refreshable bool, err error) {
+	if key, ok := ctx.Value(apiKeyOverrideKey{}).(string); ok {
+		return getAuth(key), false, nil
+	}
+	if r.credentials == nil {
+		return r.authorization, false, nil
+	}
+	key, err := r.credentials.GetAPIKey(ctx, refresh)
+	if err != nil {
+		return "", false, fmt.Errorf("get api key failed: %w", err)
+	}
+	return getAuth(key), true, nil
+}
+
+// isAuthFailure 判断响应是否为认证失败: HTTP 401, 或 RAGFlow 以 200 返回的认证错误码
+func isAuthFailure(body []byte, err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusUnauthorized
+	}
+	if err != nil {
+		return false
+	}
+	status := &apiResponse{}
+	return sonic.Unmarshal(body, status) == nil && status.Code == codeAuthenticationError
+}
@@ -0,0 +1,84 @@
+package ragflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func mustGetAPIKey(p CredentialProvider) string {
+	key, _ := p.GetAPIKey(context.Background(), false)
+	return key
+}
+
+func TestCredentialProvider(t *testing.T) {
+	PatchConvey("test CredentialProvider", t, func() {
+		ctx := context.Background()
+		server := newRetrievalServer(staticResponse(Chunk{ID: "1", Content: "c", Similarity: 0.9}))
+		defer server.Close()
+
+		PatchConvey("test refresh once on auth failure", func() {
+			server.apiKey = "new-key"
+			var refreshes int32
+			provider := CredentialProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+				if refresh {
+					atomic.AddInt32(&refreshes, 1)
+					return "new-key", nil
+				}
+				return "old-key", nil
+			})
+			r, err := NewRetriever(ctx, &RetrieverConfig{Endpoint: server.URL, DatasetIDs: []string{"kb1"}, CredentialProvider: provider})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 1)
+			convey.So(atomic.LoadInt32(&refreshes), convey.ShouldEqual, 1)
+			convey.So(len(server.received()), convey.ShouldEqual, 2)
+			convey.So(server.lastHeader().Get("Authorization"), convey.ShouldEqual, "Bearer new-key")
+		})
+
+		PatchConvey("test per call override", func() {
+			server.apiKey = "tenant-key"
+			r, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "default-key", Endpoint: server.URL, DatasetIDs: []string{"kb1"}})
+			convey.So(err, convey.ShouldBeNil)
+			_, err = r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(len(server.received()), convey.ShouldEqual, 1)
+			_, err = r.Retrieve(ctx, "q", WithAPIKey("tenant-key"))
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		PatchConvey("test built-in providers", func() {
+			convey.So(mustGetAPIKey(StaticCredential("k")), convey.ShouldEqual, "k")
+
+			t.Setenv("RAGFLOW_TEST_API_KEY", " env-key\n")
+			convey.So(mustGetAPIKey(EnvCredential("RAGFLOW_TEST_API_KEY")), convey.ShouldEqual, "env-key")
+
+			path := filepath.Join(t.TempDir(), "key")
+			convey.So(os.WriteFile(path, []byte("file-key-1\n"), 0600), convey.ShouldBeNil)
+			fc := NewFileCredential(path)
+			convey.So(mustGetAPIKey(fc), convey.ShouldEqual, "file-key-1")
+			convey.So(os.WriteFile(path, []byte("file-key-2"), 0600), convey.ShouldBeNil)
+			future := time.Now().Add(time.Minute)
+			convey.So(os.Chtimes(path, future, future), convey.ShouldBeNil)
+			convey.So(mustGetAPIKey(fc), convey.ShouldEqual, "file-key-2")
+
+			var calls int32
+			cached := NewCachedCredential(CredentialProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+				atomic.AddInt32(&calls, 1)
+				return "remote-key", nil
+			}), time.Minute)
+			_, _ = cached.GetAPIKey(ctx, false)
+			_, _ = cached.GetAPIKey(ctx, false)
+			convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 1)
+			_, _ = cached.GetAPIKey(ctx, true)
+			convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,244 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultMMRLambda          = 0.7
+	defaultNGramSize          = 3
+	defaultDiversifyOverFetch = 3
+)
+
+// RedundancyMeasure 计算文档两两之间的相似度, 返回对称矩阵, 取值范围 [0, 1], 用于衡量结果之间的冗余
+type RedundancyMeasure interface {
+	Similarities(ctx context.Context, docs []*schema.Document) ([][]float64, error)
+}
+
+// Diversification 定义了基于 MMR (Maximal Marginal Relevance) 的结果多样化: 依次选出 相关性 与 和已选结果的最大相似度 加权后得分最高的文档
+type Diversification struct {
+	// Lambda 相关性的权重, 取值 (0, 1], 越小越强调多样性, 1 表示只按相关性排序, 默认 0.7
+	Lambda float64
+	// Redundancy 冗余度的计算方式, 默认使用 NGramJaccard(3)
+	Redundancy RedundancyMeasure
+	// MaxPerDocument 每个源文档最多保留的 chunk 数, 0 表示不限制
+	MaxPerDocument int
+	// TopN 多样化后保留的结果数, 默认与返回数量一致 (见 RerankConfig.OverFetch)
+	TopN int
+	// OverFetch 过采样倍数: 向 RAGFlow 请求 返回数量*OverFetch 个 chunk 作为候选, 默认 3, 设置为 1 时不过采样.
+	// 同时开启重排时取两者中较大的倍数, 重排不再截取结果
+	OverFetch int
+}
+
+func (d *Diversification) overFetch() int {
+	switch {
+	case d == nil || d.OverFetch == 1:
+		return 1
+	case d.OverFetch <= 0:
+		return defaultDiversifyOverFetch
+	default:
+		return d.OverFetch
+	}
+}
+
+// diversify 按 MMR 从 docs 中依次选出至多 TopN 个结果, TopN 未设置时为 limit, 并丢弃超出 MaxPerDocument 的 chunk;
+// 相关性取文档得分在本批结果内的 min-max 归一化值
+func diversify(ctx context.Context, docs []*schema.Document, d *Diversification, limit int) ([]*schema.Document, error) {
+	if d == nil || len(docs) == 0 {
+		return docs, nil
+	}
+	lambda := d.Lambda
+	if lambda <= 0 || lambda > 1 {
+		lambda = defaultMMRLambda
+	}
+	measure := d.Redundancy
+	if measure == nil {
+		measure = NGramJaccard(defaultNGramSize)
+	}
+	var sims [][]float64
+	if lambda < 1 {
+		var err error
+		if sims, err = measure.Similarities(ctx, docs); err != nil {
+			return nil, fmt.Errorf("compute redundancy failed: %w", err)
+		}
+		if len(sims) != len(docs) {
+			return nil, fmt.Errorf("compute redundancy failed: got %d rows for %d docs", len(sims), len(docs))
+		}
+		for i, row := range sims {
+			if len(row) != len(docs) {
+				return nil, fmt.Errorf("compute redundancy failed: row %d has %d columns for %d docs", i, len(row), len(docs))
+			}
+		}
+	}
+
+	n := d.TopN
+	if n <= 0 {
+		n = limit
+	}
+	relevance := minMaxScores(docs)
+	selected := make([]*schema.Document, 0, len(docs))
+	selectedIdx := make([]int, 0, len(docs))
+	used := make([]bool, len(docs))
+	perDoc := map[string]int{}
+	for n <= 0 || len(selected) < n {
+		best, bestScore := -1, math.Inf(-1)
+		for i, doc := range docs {
+			if used[i] || d.MaxPerDocument > 0 && perDoc[GetOrgDocID(doc)] >= d.MaxPerDocument {
+				continue
+			}
+			redundancy := 0.0
+			for _, j := range selectedIdx {
+				if sims != nil {
+					redundancy = math.Max(redundancy, sims[i][j])
+				}
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		perDoc[GetOrgDocID(docs[best])]++
+		selected = append(selected, docs[best])
+		selectedIdx = append(selectedIdx, best)
+	}
+	return selected, nil
+}
+
+func minMaxScores(docs []*schema.Document) []float64 {
+	scores := make([]float64, len(docs))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, doc := range docs {
+		scores[i] = doc.Score()
+		lo, hi = math.Min(lo, scores[i]), math.Max(hi, scores[i])
+	}
+	for i := range scores {
+		if hi > lo {
+			scores[i] = (scores[i] - lo) / (hi - lo)
+		} else {
+			scores[i] = 1
+		}
+	}
+	return scores
+}
+
+// NGramJaccard 以字符 n-gram 集合的 Jaccard 系数衡量内容重叠, 比较前会转为小写并忽略空白与标点
+func NGramJaccard(n int) RedundancyMeasure {
+	if n <= 0 {
+		n = defaultNGramSize
+	}
+	return ngramJaccard(n)
+}
+
+type ngramJaccard int
+
+func (n ngramJaccard) Similarities(ctx context.Context, docs []*schema.Document) ([][]float64, error) {
+	sets := make([]map[string]struct{}, len(docs))
+	for i, doc := range docs {
+		sets[i] = charNGrams(doc.Content, int(n))
+	}
+	return pairwise(len(docs), func(i, j int) float64 {
+		return jaccard(sets[i], sets[j])
+	}), nil
+}
+
+func charNGrams(text string, n int) map[string]struct{} {
+	runes := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	grams := map[string]struct{}{}
+	if len(runes) < n {
+		if len(runes) > 0 {
+			grams[string(runes)] = struct{}{}
+		}
+		return grams
+	}
+	for i := 0; i+n <= len(runes); i++ {
+		grams[string(runes[i:i+n])] = struct{}{}
+	}
+	return grams
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	inter := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// EmbeddingSimilarity 使用 Embedder 为文档内容生成向量, 以余弦相似度衡量冗余, 负值按 0 处理
+func EmbeddingSimilarity(embedder embedding.Embedder) RedundancyMeasure {
+	return &embeddingSimilarity{embedder: embedder}
+}
+
+type embeddingSimilarity struct {
+	embedder embedding.Embedder
+}
+
+func (e *embeddingSimilarity) Similarities(ctx context.Context, docs []*schema.Document) ([][]float64, error) {
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Content
+	}
+	vectors, err := e.embedder.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embed documents failed: %w", err)
+	}
+	if len(vectors) != len(docs) {
+		return nil, fmt.Errorf("embed documents failed: got %d vectors for %d docs", len(vectors), len(docs))
+	}
+	return pairwise(len(docs), func(i, j int) float64 {
+		return math.Max(0, cosine(vectors[i], vectors[j]))
+	}), nil
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// pairwise 构造对称的相似度矩阵, 对角线为 1
+func pairwise(n int, sim func(i, j int) float64) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			m[i][j] = sim(i, j)
+			m[j][i] = m[i][j]
+		}
+	}
+	return m
+}
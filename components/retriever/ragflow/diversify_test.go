@@ -0,0 +1,137 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type staticEmbedder map[string][]float64
+
+func (e staticEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = e[text]
+	}
+	return vectors, nil
+}
+
+type staticRedundancy [][]float64
+
+func (r staticRedundancy) Similarities(ctx context.Context, docs []*schema.Document) ([][]float64, error) {
+	return r, nil
+}
+
+func TestDiversify(t *testing.T) {
+	PatchConvey("test Diversify", t, func() {
+		ctx := context.Background()
+		chunks := []Chunk{
+			{ID: "a1", DocumentID: "A", Content: "how to reset the password in settings page", Similarity: 0.95},
+			{ID: "a2", DocumentID: "A", Content: "how to reset the password in the settings page", Similarity: 0.94},
+			{ID: "a3", DocumentID: "A", Content: "password must contain digits", Similarity: 0.93},
+			{ID: "b1", DocumentID: "B", Content: "contact the administrator to unlock the account", Similarity: 0.80},
+		}
+		chunkIDs := func(chunks []Chunk, d *Diversification) []string {
+			docs, err := diversify(ctx, toDocs(chunks), d, 0)
+			convey.So(err, convey.ShouldBeNil)
+			ids := make([]string, 0, len(docs))
+			for _, doc := range docs {
+				ids = append(ids, GetChunkID(doc))
+			}
+			return ids
+		}
+
+		PatchConvey("test ngram mmr", func() {
+			convey.So(chunkIDs(chunks, &Diversification{Lambda: 1}), convey.ShouldResemble, []string{"a1", "a2", "a3", "b1"})
+			convey.So(chunkIDs(chunks, &Diversification{Lambda: 0.3}), convey.ShouldResemble, []string{"a1", "a3", "b1", "a2"})
+		})
+
+		PatchConvey("test max per document", func() {
+			convey.So(chunkIDs(chunks, &Diversification{Lambda: 1, MaxPerDocument: 2}), convey.ShouldResemble, []string{"a1", "a2", "b1"})
+		})
+
+		PatchConvey("test result count", func() {
+			convey.So(chunkIDs(chunks, &Diversification{Lambda: 0.3, TopN: 2}), convey.ShouldResemble, []string{"a1", "a3"})
+			docs, err := diversify(ctx, toDocs(chunks), &Diversification{Lambda: 0.3}, 3)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 3)
+			convey.So(GetChunkID(docs[2]), convey.ShouldEqual, "b1")
+		})
+
+		PatchConvey("test embedding similarity", func() {
+			embedder := staticEmbedder{
+				chunks[0].Content: {1, 0},
+				chunks[1].Content: {0, 1},
+				chunks[2].Content: {1, 0.01},
+				chunks[3].Content: {-1, 0},
+			}
+			ids := chunkIDs(chunks, &Diversification{Lambda: 0.5, Redundancy: EmbeddingSimilarity(embedder)})
+			convey.So(ids, convey.ShouldResemble, []string{"a1", "a2", "b1", "a3"})
+		})
+
+		PatchConvey("test invalid redundancy matrix", func() {
+			ragged := staticRedundancy{{1, 0, 0, 0}, {0, 1}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+			_, err := diversify(ctx, toDocs(chunks), &Diversification{Lambda: 0.5, Redundancy: ragged}, 0)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "row 1 has 2 columns")
+
+			_, err = diversify(ctx, toDocs(chunks), &Diversification{Lambda: 0.5, Redundancy: ragged[:2]}, 0)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test retrieve with diversification", func() {
+			server := newRetrievalServer(staticResponse(chunks...))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:          "key",
+				Endpoint:        server.URL,
+				DatasetIDs:      []string{"kb1"},
+				Diversification: &Diversification{MaxPerDocument: 1},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"A", "B"})
+
+			docs, err = r.Retrieve(ctx, "q", WithDiversification(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 4)
+		})
+
+		PatchConvey("test retrieve over fetches candidates for diversification", func() {
+			server := newRetrievalServer(staticResponse(chunks...))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:                 "key",
+				Endpoint:               server.URL,
+				DatasetIDs:             []string{"kb1"},
+				RetrievalRequestOption: &RetrievalRequestOption{PageSize: ptrOf(2)},
+				Rerank:                 &RerankConfig{Reranker: BM25Reranker{}},
+				Diversification:        &Diversification{Lambda: 0.3},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "reset password")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(*server.received()[0].PageSize, convey.ShouldEqual, 6)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+			convey.So(GetChunkID(docs[0]), convey.ShouldNotEqual, "a2")
+			convey.So(GetChunkID(docs[1]), convey.ShouldNotEqual, "a2")
+
+			_, err = r.Retrieve(ctx, "reset password", WithDiversification(&Diversification{OverFetch: 1}), WithRerank(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(*server.received()[1].PageSize, convey.ShouldEqual, 2)
+		})
+	})
+}
+
+func toDocs(chunks []Chunk) []*schema.Document {
+	docs := make([]*schema.Document, 0, len(chunks))
+	for i := range chunks {
+		docs = append(docs, chunks[i].toDoc(&implOptions{}))
+	}
+	return docs
+}
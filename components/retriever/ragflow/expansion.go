@@ -0,0 +1,288 @@
+package ragflow
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	contextBeforeKey = "context_before"
+	contextAfterKey  = "context_after"
+
+	defaultExpansionConcurrency = 4
+	defaultExpansionSeparator   = "\n"
+
+	defaultChunkCacheTTL          = 5 * time.Minute
+	defaultChunkCacheMaxDocuments = 128
+)
+
+// ExpansionMode 决定相邻 chunk 的输出方式
+type ExpansionMode string
+
+const (
+	// ExpansionModeMerge 将相邻 chunk 与命中 chunk 按文档顺序合并为一个更大的文档 (默认)
+	ExpansionModeMerge ExpansionMode = ""
+	// ExpansionModeMetadata 保持 Content 不变, 相邻 chunk 通过 GetContextBefore/GetContextAfter 获取
+	ExpansionModeMetadata ExpansionMode = "metadata"
+)
+
+func (m ExpansionMode) valid() bool {
+	switch m {
+	case ExpansionModeMerge, ExpansionModeMetadata:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContextExpansion 定义了相邻 chunk 扩展的参数
+type ContextExpansion struct {
+	// Before 向前扩展的 chunk 数量
+	Before int
+	// After 向后扩展的 chunk 数量
+	After int
+	// Mode 相邻 chunk 的输出方式, 默认合并
+	Mode ExpansionMode
+	// Separator 合并时 chunk 之间的分隔符, 默认为换行
+	Separator string
+	// MaxConcurrency 同时拉取 chunk 列表的文档数, 默认 4
+	MaxConcurrency int
+}
+
+// ChunkCacheConfig 定义了相邻 chunk 扩展使用的文档 chunk 列表缓存
+type ChunkCacheConfig struct {
+	// TTL 缓存有效期, 默认 5 分钟, 小于 0 时不缓存
+	TTL time.Duration
+	// MaxDocuments 最多缓存的文档数, 超出时淘汰最久未使用的文档, 默认 128
+	MaxDocuments int
+}
+
+type expansionTarget struct {
+	datasetID  string
+	documentID string
+}
+
+type chunkCacheEntry struct {
+	target    expansionTarget
+	chunks    []DocumentChunk
+	expiresAt time.Time
+}
+
+// chunkCache 按 (数据集, 文档) 缓存 chunk 列表, 过期或超出容量 (LRU) 的文档会被淘汰
+type chunkCache struct {
+	ttl          time.Duration
+	maxDocuments int
+
+	mu      sync.Mutex
+	entries map[expansionTarget]*list.Element
+	lru     *list.List
+}
+
+func newChunkCache(config *ChunkCacheConfig) *chunkCache {
+	c := &chunkCache{ttl: defaultChunkCacheTTL, maxDocuments: defaultChunkCacheMaxDocuments}
+	if config != nil {
+		if config.TTL < 0 {
+			return nil
+		}
+		if config.TTL > 0 {
+			c.ttl = config.TTL
+		}
+		if config.MaxDocuments > 0 {
+			c.maxDocuments = config.MaxDocuments
+		}
+	}
+	c.entries = make(map[expansionTarget]*list.Element)
+	c.lru = list.New()
+	return c
+}
+
+func (c *chunkCache) get(t expansionTarget) ([]DocumentChunk, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[t]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*chunkCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(elem)
+		delete(c.entries, t)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.chunks, true
+}
+
+func (c *chunkCache) put(t expansionTarget, chunks []DocumentChunk) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &chunkCacheEntry{target: t, chunks: chunks, expiresAt: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[t]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[t] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxDocuments {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*chunkCacheEntry).target)
+	}
+}
+
+// documentChunks 返回文档的 chunk 列表, 优先使用缓存.
+// chunk 的先后顺序直接沿用列表接口的返回顺序 (即文档内顺序), 列表接口不返回可比较的位置信息
+func (r *Retriever) documentChunks(ctx context.Context, t expansionTarget) ([]DocumentChunk, error) {
+	if chunks, ok := r.chunkCache.get(t); ok {
+		return chunks, nil
+	}
+	chunks, err := r.ListChunks(ctx, t.datasetID, t.documentID)
+	if err != nil {
+		return nil, err
+	}
+	r.chunkCache.put(t, chunks)
+	return chunks, nil
+}
+
+// expandContext 为命中的文档补充前后相邻的 chunk, 同一文档的 chunk 列表在一次调用中只拉取一次, 并在 Retriever 上按文档缓存
+func (r *Retriever) expandContext(ctx context.Context, docs []*schema.Document, exp *ContextExpansion, format ContentFormat) error {
+	if exp == nil || (exp.Before <= 0 && exp.After <= 0) || len(docs) == 0 {
+		return nil
+	}
+
+	groups := make(map[expansionTarget][]*schema.Document)
+	targets := make([]expansionTarget, 0)
+	for _, doc := range docs {
+		if GetChunkID(doc) == "" {
+			continue
+		}
+		t := expansionTarget{datasetID: GetDatasetID(doc), documentID: GetOrgDocID(doc)}
+		if _, ok := groups[t]; !ok {
+			targets = append(targets, t)
+		}
+		groups[t] = append(groups[t], doc)
+	}
+
+	concurrency := exp.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultExpansionConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t expansionTarget) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			chunks, err := r.documentChunks(ctx, t)
+			if err != nil {
+				errs[i] = fmt.Errorf("expand context of document %s failed: %w", t.documentID, err)
+				return
+			}
+			for _, doc := range groups[t] {
+				applyExpansion(doc, chunks, exp, format)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func applyExpansion(doc *schema.Document, chunks []DocumentChunk, exp *ContextExpansion, format ContentFormat) {
+	chunkID := GetChunkID(doc)
+	idx := -1
+	for i := range chunks {
+		if chunks[i].ID == chunkID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return
+	}
+
+	neighbor := func(c DocumentChunk) string {
+		if format == ContentFormatRaw {
+			return c.Content
+		}
+		return sanitize(c.Content)
+	}
+	before := make([]string, 0, exp.Before)
+	for i := max(0, idx-exp.Before); i < idx; i++ {
+		before = append(before, neighbor(chunks[i]))
+	}
+	after := make([]string, 0, exp.After)
+	for i := idx + 1; i < len(chunks) && i <= idx+exp.After; i++ {
+		after = append(after, neighbor(chunks[i]))
+	}
+
+	if exp.Mode == ExpansionModeMetadata {
+		doc.MetaData[contextBeforeKey] = before
+		doc.MetaData[contextAfterKey] = after
+		return
+	}
+
+	sep := exp.Separator
+	if sep == "" {
+		sep = defaultExpansionSeparator
+	}
+	var prefix, suffix string
+	if len(before) > 0 {
+		prefix = strings.Join(before, sep) + sep
+	}
+	if len(after) > 0 {
+		suffix = sep + strings.Join(after, sep)
+	}
+	doc.Content = prefix + doc.Content + suffix
+	// 合并后命中词的偏移需要整体后移
+	if spans := GetHighlights(doc); len(spans) > 0 && prefix != "" {
+		shifted := make([]HighlightSpan, len(spans))
+		for i, s := range spans {
+			shifted[i] = HighlightSpan{Start: s.Start + len(prefix), End: s.End + len(prefix), Text: s.Text}
+		}
+		setHighlights(doc, shifted)
+	}
+}
+
+// GetContextBefore 返回 ExpansionModeMetadata 下命中 chunk 之前的相邻 chunk 内容
+func GetContextBefore(doc *schema.Document) []string {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[contextBeforeKey]; ok {
+		return v.([]string)
+	}
+	return nil
+}
+
+// GetContextAfter 返回 ExpansionModeMetadata 下命中 chunk 之后的相邻 chunk 内容
+func GetContextAfter(doc *schema.Document) []string {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[contextAfterKey]; ok {
+		return v.([]string)
+	}
+	return nil
+}
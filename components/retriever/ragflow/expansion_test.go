@@ -0,0 +1,101 @@
+package ragflow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestExpandContext(t *testing.T) {
+	PatchConvey("test expandContext", t, func() {
+		ctx := context.Background()
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			if req.URL.Path != "/api/v1/datasets/kb1/documents/doc1/chunks" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			res := &listChunksResponse{}
+			for _, id := range []string{"c1", "c2", "c3", "c4", "c5"} {
+				res.Data.Chunks = append(res.Data.Chunks, DocumentChunk{ID: id, Content: "content " + id})
+			}
+			res.Data.Total = 5
+			_ = json.NewEncoder(w).Encode(res)
+		}))
+		defer server.Close()
+
+		r := &Retriever{
+			config: &RetrieverConfig{Endpoint: server.URL},
+			client: server.Client(),
+		}
+		newDoc := func(chunkID string) *schema.Document {
+			doc := (&Chunk{ID: chunkID, KbID: "kb1", DocumentID: "doc1", Content: "content " + chunkID}).toDoc(&implOptions{})
+			setHighlights(doc, []HighlightSpan{{Start: 0, End: 7, Text: "content"}})
+			return doc
+		}
+
+		PatchConvey("test merge mode", func() {
+			docs := []*schema.Document{newDoc("c1"), newDoc("c3")}
+			err := r.expandContext(ctx, docs, &ContextExpansion{Before: 1, After: 1}, ContentFormatRaw)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docs[0].Content, convey.ShouldEqual, "content c1\ncontent c2")
+			convey.So(docs[1].Content, convey.ShouldEqual, "content c2\ncontent c3\ncontent c4")
+			convey.So(GetHighlights(docs[1])[0].Start, convey.ShouldEqual, len("content c2\n"))
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 1)
+		})
+
+		PatchConvey("test metadata mode", func() {
+			docs := []*schema.Document{newDoc("c5")}
+			err := r.expandContext(ctx, docs, &ContextExpansion{Before: 2, After: 2, Mode: ExpansionModeMetadata}, ContentFormatRaw)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docs[0].Content, convey.ShouldEqual, "content c5")
+			convey.So(GetContextBefore(docs[0]), convey.ShouldResemble, []string{"content c3", "content c4"})
+			convey.So(GetContextAfter(docs[0]), convey.ShouldResemble, []string{})
+		})
+
+		PatchConvey("test chunk cache", func() {
+			cached := &Retriever{
+				config:     r.config,
+				client:     r.client,
+				chunkCache: newChunkCache(&ChunkCacheConfig{TTL: time.Minute, MaxDocuments: 1}),
+			}
+			for i := 0; i < 2; i++ {
+				docs := []*schema.Document{newDoc("c2")}
+				convey.So(cached.expandContext(ctx, docs, &ContextExpansion{Before: 1}, ContentFormatRaw), convey.ShouldBeNil)
+				convey.So(docs[0].Content, convey.ShouldEqual, "content c1\ncontent c2")
+			}
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 1)
+
+			// 超出容量时淘汰最久未使用的文档
+			cached.chunkCache.put(expansionTarget{datasetID: "kb1", documentID: "doc2"}, nil)
+			_, ok := cached.chunkCache.get(expansionTarget{datasetID: "kb1", documentID: "doc1"})
+			convey.So(ok, convey.ShouldBeFalse)
+
+			// 过期后重新拉取
+			cached.chunkCache.ttl = time.Nanosecond
+			cached.chunkCache.put(expansionTarget{datasetID: "kb1", documentID: "doc1"}, nil)
+			time.Sleep(time.Millisecond)
+			_, ok = cached.chunkCache.get(expansionTarget{datasetID: "kb1", documentID: "doc1"})
+			convey.So(ok, convey.ShouldBeFalse)
+
+			convey.So(newChunkCache(&ChunkCacheConfig{TTL: -1}), convey.ShouldBeNil)
+		})
+
+		PatchConvey("test list error", func() {
+			doc := newDoc("c1")
+			setDatasetID(doc, "missing")
+			err := r.expandContext(ctx, []*schema.Document{doc}, &ContextExpansion{Before: 1}, ContentFormatRaw)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "expand context of document doc1 failed")
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package ragflow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// ExtraKeyRateLimitWait 本次检索因客户端限流累计等待的时间 (time.Duration), 位于 retriever.CallbackOutput.Extra
+	ExtraKeyRateLimitWait = "rate_limit_wait"
+	// ExtraKeyCircuitTransitions 本次检索期间熔断器的状态变化 ([]CircuitTransition), 仅在发生变化时存在
+	ExtraKeyCircuitTransitions = "circuit_transitions"
+	// ExtraKeyFallback 熔断器打开时本次检索是否由 Fallback 完成 (bool)
+	ExtraKeyFallback = "fallback"
+	// ExtraKeyHedged 本次检索是否发出了对冲请求 (bool)
+	ExtraKeyHedged = "hedged"
+	// ExtraKeyHedgeWon 本次检索是否由对冲请求返回结果 (bool)
+	ExtraKeyHedgeWon = "hedge_won"
+	// ExtraKeyAppliedThreshold 自适应阈值模式下实际使用的阈值 (float64), 仅在开启自适应阈值时存在
+	ExtraKeyAppliedThreshold = "applied_threshold"
+)
+
+type callStatsKey struct{}
+
+// callStats 记录一次 Retrieve 调用中各个 HTTP 请求的统计信息, 结束时写入回调的 Extra
+type callStats struct {
+	mu                 sync.Mutex
+	rateLimitWait      time.Duration
+	circuitTransitions []CircuitTransition
+	fallback           bool
+	hedged             bool
+	hedgeWon           bool
+	appliedThreshold   *float64
+	twoStage           *TwoStageInfo
+	expandedQueries    []string
+	routing            *RoutingInfo
+}
+
+func withCallStats(ctx context.Context) (context.Context, *callStats) {
+	stats := &callStats{}
+	return context.WithValue(ctx, callStatsKey{}, stats), stats
+}
+
+func getCallStats(ctx context.Context) *callStats {
+	stats, _ := ctx.Value(callStatsKey{}).(*callStats)
+	return stats
+}
+
+func (s *callStats) addRateLimitWait(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.rateLimitWait += d
+	s.mu.Unlock()
+}
+
+func (s *callStats) addCircuitTransition(t CircuitTransition) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.circuitTransitions = append(s.circuitTransitions, t)
+	s.mu.Unlock()
+}
+
+func (s *callStats) setFallback() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.fallback = true
+	s.mu.Unlock()
+}
+
+func (s *callStats) setHedged() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.hedged = true
+	s.mu.Unlock()
+}
+
+func (s *callStats) setHedgeWon() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.hedgeWon = true
+	s.mu.Unlock()
+}
+
+func (s *callStats) setAppliedThreshold(threshold float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.appliedThreshold = &threshold
+	s.mu.Unlock()
+}
+
+func (s *callStats) setTwoStage(info *TwoStageInfo) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.twoStage = info
+	s.mu.Unlock()
+}
+
+func (s *callStats) addExpandedQuery(query string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.expandedQueries = append(s.expandedQueries, query)
+	s.mu.Unlock()
+}
+
+func (s *callStats) setRouting(info *RoutingInfo) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.routing = info
+	s.mu.Unlock()
+}
+
+func (s *callStats) extra() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	extra := map[string]any{
+		ExtraKeyRateLimitWait: s.rateLimitWait,
+		ExtraKeyFallback:      s.fallback,
+		ExtraKeyHedged:        s.hedged,
+		ExtraKeyHedgeWon:      s.hedgeWon,
+	}
+	if len(s.circuitTransitions) > 0 {
+		extra[ExtraKeyCircuitTransitions] = s.circuitTransitions
+	}
+	if s.appliedThreshold != nil {
+		extra[ExtraKeyAppliedThreshold] = *s.appliedThreshold
+	}
+	if s.twoStage != nil {
+		extra[ExtraKeyTwoStage] = s.twoStage
+	}
+	if len(s.expandedQueries) > 0 {
+		extra[ExtraKeyExpandedQueries] = s.expandedQueries
+	}
+	if s.routing != nil {
+		extra[ExtraKeyRouting] = s.routing
+	}
+	return extra
+}
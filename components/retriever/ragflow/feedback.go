@@ -0,0 +1,173 @@
+package ragflow
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/components/retriever"
+)
+
+const (
+	// ExtraKeyExpandedQueries 关键词反馈扩展后发出的查询 ([]string), 仅在开启关键词反馈时存在
+	ExtraKeyExpandedQueries = "expanded_queries"
+
+	defaultFeedbackTopN        = 5
+	defaultFeedbackMaxKeywords = 5
+	defaultFeedbackIterations  = 1
+	defaultRRFK                = 60
+)
+
+// Feedback 定义了基于 ImportantKeywords 的伪相关反馈: 从前 TopN 个命中中按相似度加权选出关键词, 追加到查询后再次检索,
+// 并以 RRF (Reciprocal Rank Fusion) 融合各轮结果
+type Feedback struct {
+	// TopN 用于提取关键词的命中数, 默认 5
+	TopN int
+	// MaxKeywords 每轮追加到查询中的关键词数, 默认 5
+	MaxKeywords int
+	// Iterations 反馈轮数, 默认 1; 某一轮没有新的关键词时提前结束
+	Iterations int
+	// RRFK RRF 的平滑参数, 默认 60
+	RRFK int
+}
+
+func (f *Feedback) withDefaults() Feedback {
+	c := *f
+	if c.TopN <= 0 {
+		c.TopN = defaultFeedbackTopN
+	}
+	if c.MaxKeywords <= 0 {
+		c.MaxKeywords = defaultFeedbackMaxKeywords
+	}
+	if c.Iterations <= 0 {
+		c.Iterations = defaultFeedbackIterations
+	}
+	if c.RRFK <= 0 {
+		c.RRFK = defaultRRFK
+	}
+	return c
+}
+
+// search 执行一次检索, 开启两阶段检索时包含两个阶段
+func (r *Retriever) search(ctx context.Context, query string, option *retriever.Options, target *searchTarget,
+	pageSize int, implOpts *implOptions) (*successResponse, error) {
+	if implOpts.TwoStage != nil {
+		return r.twoStageSearch(ctx, query, option, target, pageSize, implOpts.TwoStage)
+	}
+	return r.doPost(ctx, query, option, target, pageSize)
+}
+
+// feedbackSearch 在首轮结果的基础上执行关键词反馈检索, 扩展检索失败时保留已有结果.
+// 融合结果是各轮结果的并集, 按 RRF 排序后只保留前 keep 个, 与单轮检索返回的数量一致
+func (r *Retriever) feedbackSearch(ctx context.Context, query string, option *retriever.Options, target *searchTarget,
+	pageSize, keep int, implOpts *implOptions, initial *successResponse) *successResponse {
+	c := implOpts.Feedback.withDefaults()
+	lists := [][]Chunk{initial.Data.Chunks}
+	fused := initial
+	expanded := query
+	for i := 0; i < c.Iterations; i++ {
+		keywords := feedbackKeywords(fused.Data.Chunks, expanded, c.TopN, c.MaxKeywords)
+		if len(keywords) == 0 {
+			break
+		}
+		expanded = expanded + " " + strings.Join(keywords, " ")
+		getCallStats(ctx).addExpandedQuery(expanded)
+		res, err := r.search(ctx, expanded, option, target, pageSize, implOpts)
+		if err != nil {
+			log.Printf("[Warn]ragflow feedback retrieval failed, keep previous results: %v", err)
+			break
+		}
+		lists = append(lists, res.Data.Chunks)
+		chunks := fuseChunks(lists, c.RRFK)
+		if keep > 0 && len(chunks) > keep {
+			chunks = chunks[:keep]
+		}
+		fused = &successResponse{
+			Code: initial.Code,
+			Data: Data{
+				Chunks:  chunks,
+				DocAggs: mergeDocAggs(fused.Data.DocAggs, res.Data.DocAggs),
+			},
+		}
+		fused.Data.Total = int64(len(fused.Data.Chunks))
+	}
+	return fused
+}
+
+// feedbackKeywords 从前 topN 个命中的 ImportantKeywords 中按相似度加权选出不在查询中的关键词
+func feedbackKeywords(chunks []Chunk, query string, topN, maxKeywords int) []string {
+	lowerQuery := strings.ToLower(query)
+	weights := map[string]float64{}
+	display := map[string]string{}
+	var order []string
+	for i := 0; i < len(chunks) && i < topN; i++ {
+		for _, kw := range chunks[i].ImportantKeywords {
+			kw = strings.TrimSpace(kw)
+			key := strings.ToLower(kw)
+			if key == "" || strings.Contains(lowerQuery, key) {
+				continue
+			}
+			if _, ok := weights[key]; !ok {
+				display[key] = kw
+				order = append(order, key)
+			}
+			weights[key] += chunks[i].Similarity
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return weights[order[i]] > weights[order[j]]
+	})
+	if len(order) > maxKeywords {
+		order = order[:maxKeywords]
+	}
+	keywords := make([]string, 0, len(order))
+	for _, key := range order {
+		keywords = append(keywords, display[key])
+	}
+	return keywords
+}
+
+// fuseChunks 以 RRF 融合多个结果列表, 同一个 chunk 的相似度取各列表中的最大值
+func fuseChunks(lists [][]Chunk, k int) []Chunk {
+	scores := map[string]float64{}
+	chunks := map[string]Chunk{}
+	var order []string
+	for _, list := range lists {
+		for rank, chunk := range list {
+			prev, ok := chunks[chunk.ID]
+			if !ok {
+				order = append(order, chunk.ID)
+				chunks[chunk.ID] = chunk
+			} else if chunk.Similarity > prev.Similarity {
+				chunks[chunk.ID] = chunk
+			}
+			scores[chunk.ID] += 1 / float64(k+rank+1)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	fused := make([]Chunk, 0, len(order))
+	for _, id := range order {
+		fused = append(fused, chunks[id])
+	}
+	return fused
+}
+
+func mergeDocAggs(a, b []DocAgg) []DocAgg {
+	merged := append([]DocAgg(nil), a...)
+	index := make(map[string]int, len(merged))
+	for i, agg := range merged {
+		index[agg.DocID] = i
+	}
+	for _, agg := range b {
+		if i, ok := index[agg.DocID]; ok {
+			merged[i].Count = max(merged[i].Count, agg.Count)
+			continue
+		}
+		index[agg.DocID] = len(merged)
+		merged = append(merged, agg)
+	}
+	return merged
+}
@@ -0,0 +1,89 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeedback(t *testing.T) {
+	PatchConvey("test Feedback", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test keywords and fusion", func() {
+			chunks := []Chunk{
+				{ID: "1", Similarity: 0.9, ImportantKeywords: []string{"Reset", "account"}},
+				{ID: "2", Similarity: 0.5, ImportantKeywords: []string{"unlock", "Account"}},
+				{ID: "3", Similarity: 0.4, ImportantKeywords: []string{"ignored"}},
+			}
+			convey.So(feedbackKeywords(chunks, "reset password", 2, 5), convey.ShouldResemble, []string{"account", "unlock"})
+			convey.So(feedbackKeywords(chunks, "reset password", 2, 1), convey.ShouldResemble, []string{"account"})
+
+			fused := fuseChunks([][]Chunk{
+				{{ID: "a", Similarity: 0.9}, {ID: "b", Similarity: 0.8}, {ID: "c", Similarity: 0.7}},
+				{{ID: "c", Similarity: 0.95}, {ID: "d", Similarity: 0.6}},
+			}, 60)
+			ids := make([]string, 0, len(fused))
+			for _, chunk := range fused {
+				ids = append(ids, chunk.ID)
+			}
+			convey.So(ids, convey.ShouldResemble, []string{"c", "a", "b", "d"})
+			convey.So(fused[0].Similarity, convey.ShouldEqual, 0.95)
+		})
+
+		PatchConvey("test retrieve with feedback", func() {
+			server := newRetrievalServer(func(req *request) *successResponse {
+				if req.Question == "reset password" {
+					return &successResponse{Data: Data{Chunks: []Chunk{
+						{ID: "1", DocumentID: "1", Similarity: 0.9, ImportantKeywords: []string{"account", "unlock"}},
+					}}}
+				}
+				return &successResponse{Data: Data{Chunks: []Chunk{
+					{ID: "2", DocumentID: "2", Similarity: 0.8, ImportantKeywords: []string{"admin"}},
+					{ID: "1", DocumentID: "1", Similarity: 0.7},
+				}}}
+			})
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Feedback:   &Feedback{Iterations: 2, MaxKeywords: 2},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			cbCtx, rec := newCallbackContext(ctx)
+			docs, err := r.Retrieve(cbCtx, "reset password")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1", "2"})
+			convey.So(docs[0].Score(), convey.ShouldEqual, 0.9)
+
+			queries := rec.output.Extra[ExtraKeyExpandedQueries].([]string)
+			convey.So(queries, convey.ShouldResemble, []string{"reset password account unlock", "reset password account unlock admin"})
+			reqs := server.received()
+			convey.So(len(reqs), convey.ShouldEqual, 3)
+			convey.So(reqs[2].Question, convey.ShouldEqual, "reset password account unlock admin")
+
+			_, err = r.Retrieve(ctx, "reset password", WithFeedback(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(server.received()), convey.ShouldEqual, 4)
+
+			// 融合结果不超过单轮检索的返回数量
+			limited, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:                 "key",
+				Endpoint:               server.URL,
+				DatasetIDs:             []string{"kb1"},
+				RetrievalRequestOption: &RetrievalRequestOption{TopK: ptrOf(1024), PageSize: ptrOf(1)},
+				Feedback:               &Feedback{Iterations: 2, MaxKeywords: 2},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err = limited.Retrieve(ctx, "reset password")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1"})
+			docs, err = limited.Retrieve(ctx, "reset password", WithPageSize(2))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1", "2"})
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// HeaderFunc 根据请求的 ctx 生成额外的请求头, 例如透传 W3C traceparent 或请求 ID; 返回 nil 表示不添加
+type HeaderFunc func(ctx context.Context) http.Header
+
+// ContextValueHeaders 返回一个 HeaderFunc, 将 ctx 中的字符串值按 请求头名 -> ctx key 的映射写入请求头, 值为空时跳过
+func ContextValueHeaders(keys map[string]any) HeaderFunc {
+	return func(ctx context.Context) http.Header {
+		header := http.Header{}
+		for name, key := range keys {
+			if value, ok := ctx.Value(key).(string); ok && value != "" {
+				header.Set(name, value)
+			}
+		}
+		return header
+	}
+}
+
+// reservedHeaders 由 Retriever 自行维护, 不允许通过自定义请求头覆盖
+var reservedHeaders = map[string]bool{
+	"Authorization": true,
+	"Content-Type":  true,
+}
+
+func validateHeaders(headers map[string]string) error {
+	for name := range headers {
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s can not be customized", name)
+		}
+	}
+	return nil
+}
+
+type callHeadersKey struct{}
+
+func withCallHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, callHeadersKey{}, headers)
+}
+
+// setHeaders 按 RetrieverConfig.Headers < RetrieverConfig.HeaderFunc < WithHeaders 的优先级写入自定义请求头
+func (r *Retriever) setHeaders(ctx context.Context, req *http.Request) {
+	for name, value := range r.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if r.config.HeaderFunc != nil {
+		for name, values := range r.config.HeaderFunc(ctx) {
+			if reservedHeaders[http.CanonicalHeaderKey(name)] {
+				continue
+			}
+			req.Header.Del(name)
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+	if headers, ok := ctx.Value(callHeadersKey{}).(map[string]string); ok {
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ragflow
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type requestIDKey struct{}
+
+func TestHeaders(t *testing.T) {
+	PatchConvey("test Headers", t, func() {
+		ctx := context.Background()
+		server := newRetrievalServer(staticResponse(Chunk{ID: "1", Content: "c", Similarity: 0.9}))
+		defer server.Close()
+
+		PatchConvey("test static, context and per call headers", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Headers:    map[string]string{"X-Tenant": "t1", "X-Request-Id": "static"},
+				HeaderFunc: func(ctx context.Context) http.Header {
+					header := ContextValueHeaders(map[string]any{"X-Request-Id": requestIDKey{}})(ctx)
+					header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+					header.Set("Authorization", "Bearer hijacked")
+					return header
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = r.Retrieve(context.WithValue(ctx, requestIDKey{}, "req-1"), "q")
+			convey.So(err, convey.ShouldBeNil)
+			header := server.lastHeader()
+			convey.So(header.Get("X-Tenant"), convey.ShouldEqual, "t1")
+			convey.So(header.Get("X-Request-Id"), convey.ShouldEqual, "req-1")
+			convey.So(header.Get("Traceparent"), convey.ShouldStartWith, "00-4bf92f")
+			convey.So(header.Get("Authorization"), convey.ShouldEqual, "Bearer key")
+
+			_, err = r.Retrieve(ctx, "q", WithHeaders(map[string]string{"X-Tenant": "t2"}))
+			convey.So(err, convey.ShouldBeNil)
+			header = server.lastHeader()
+			convey.So(header.Get("X-Tenant"), convey.ShouldEqual, "t2")
+			convey.So(header.Get("X-Request-Id"), convey.ShouldEqual, "static")
+		})
+
+		PatchConvey("test reserved headers", func() {
+			_, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Headers:    map[string]string{"authorization": "Bearer x"},
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+
+			r, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "key", Endpoint: server.URL, DatasetIDs: []string{"kb1"}})
+			convey.So(err, convey.ShouldBeNil)
+			_, err = r.Retrieve(ctx, "q", WithHeaders(map[string]string{"Content-Type": "text/plain"}))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(len(server.received()), convey.ShouldEqual, 0)
+		})
+	})
+}
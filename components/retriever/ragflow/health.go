@@ -0,0 +1,129 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// codeAuthenticationError 是 RAGFlow 在 API Key 无效时返回的业务错误码
+const codeAuthenticationError = 109
+
+// AuthStatus 是 API Key 的认证状态
+type AuthStatus string
+
+const (
+	// AuthStatusUnknown 服务不可达, 无法判断
+	AuthStatusUnknown AuthStatus = "unknown"
+	// AuthStatusValid API Key 有效
+	AuthStatusValid AuthStatus = "valid"
+	// AuthStatusInvalid API Key 无效
+	AuthStatusInvalid AuthStatus = "invalid"
+)
+
+// HealthStatus 是一次健康检查的结果
+type HealthStatus struct {
+	// Reachable RAGFlow 服务是否可达
+	Reachable bool `json:"reachable"`
+	// Auth API Key 的认证状态
+	Auth AuthStatus `json:"auth"`
+	// Latency 认证探测请求的耗时
+	Latency time.Duration `json:"latency"`
+	// Version RAGFlow 服务版本, 服务未提供时为空
+	Version string `json:"version,omitempty"`
+	// Error 检查失败的原因
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Healthy 表示服务可达且 API Key 有效
+func (s *HealthStatus) Healthy() bool {
+	return s != nil && s.Reachable && s.Auth == AuthStatusValid
+}
+
+// HealthChecker 由 Retriever 实现, 也可以由其他需要挂载到就绪探针的组件实现
+type HealthChecker interface {
+	Health(ctx context.Context) *HealthStatus
+}
+
+type versionResponse struct {
+	Code int    `json:"code"`
+	Data string `json:"data"`
+}
+
+// Health 探测 RAGFlow 服务是否可达, API Key 是否有效, 并尽量获取服务版本
+func (r *Retriever) Health(ctx context.Context) *HealthStatus {
+	status := &HealthStatus{Auth: AuthStatusUnknown, CheckedAt: time.Now()}
+
+	start := time.Now()
+	err := r.getJSON(ctx, r.apiURL("/datasets", url.Values{"page": {"1"}, "page_size": {"1"}}), &apiResponse{})
+	status.Latency = time.Since(start)
+
+	var apiErr *APIError
+	var httpErr *HTTPError
+	switch {
+	case err == nil:
+		status.Reachable, status.Auth = true, AuthStatusValid
+	case errors.As(err, &apiErr):
+		status.Reachable = true
+		status.Error = err.Error()
+		if apiErr.Code == codeAuthenticationError {
+			status.Auth = AuthStatusInvalid
+		}
+	case errors.As(err, &httpErr):
+		status.Reachable = true
+		status.Error = err.Error()
+		if httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden {
+			status.Auth = AuthStatusInvalid
+		}
+	default:
+		status.Error = err.Error()
+		return status
+	}
+
+	// 版本接口并非所有部署都开放, 获取失败不影响健康状态
+	version := &versionResponse{}
+	versionURL := strings.TrimRight(r.config.Endpoint, "/") + "/v1/system/version"
+	if err := r.getJSON(ctx, versionURL, version); err == nil {
+		status.Version = version.Data
+	}
+	return status
+}
+
+// Ping 在服务不可达或 API Key 无效时返回错误
+func (r *Retriever) Ping(ctx context.Context) error {
+	status := r.Health(ctx)
+	if status.Healthy() {
+		return nil
+	}
+	return fmt.Errorf("ragflow is not healthy: reachable=%v, auth=%s, error=%s", status.Reachable, status.Auth, status.Error)
+}
+
+// HealthHandler 将 HealthChecker 适配为 http.Handler, 可以直接挂载到就绪探针路由.
+// 健康时返回 200, 否则返回 503, 响应体为 JSON 格式的 HealthStatus
+func HealthHandler(checker HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		status := checker.Health(req.Context())
+		body, err := sonic.Marshal(status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status.Healthy() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if _, err = w.Write(body); err != nil {
+			log.Printf("[Error]failed to write health response:%v", err)
+		}
+	})
+}
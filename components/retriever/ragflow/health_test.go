@@ -0,0 +1,71 @@
+package ragflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealth(t *testing.T) {
+	PatchConvey("test Health", t, func() {
+		ctx := context.Background()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer good" {
+				_, _ = w.Write([]byte(`{"code":109,"message":"Authentication error: API key is invalid!","data":false}`))
+				return
+			}
+			switch req.URL.Path {
+			case "/api/v1/datasets":
+				_, _ = w.Write([]byte(`{"code":0,"data":[]}`))
+			case "/v1/system/version":
+				_, _ = w.Write([]byte(`{"code":0,"data":"v0.19.0"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		newRetriever := func(apiKey string) *Retriever {
+			r, err := NewRetriever(ctx, &RetrieverConfig{APIKey: apiKey, Endpoint: server.URL, DatasetIDs: []string{"kb1"}})
+			convey.So(err, convey.ShouldBeNil)
+			return r
+		}
+
+		PatchConvey("test healthy", func() {
+			r := newRetriever("good")
+			status := r.Health(ctx)
+			convey.So(status.Healthy(), convey.ShouldBeTrue)
+			convey.So(status.Version, convey.ShouldEqual, "v0.19.0")
+			convey.So(r.Ping(ctx), convey.ShouldBeNil)
+
+			rec := httptest.NewRecorder()
+			HealthHandler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(rec.Body.String(), convey.ShouldContainSubstring, `"auth":"valid"`)
+		})
+
+		PatchConvey("test invalid api key", func() {
+			r := newRetriever("bad")
+			status := r.Health(ctx)
+			convey.So(status.Reachable, convey.ShouldBeTrue)
+			convey.So(status.Auth, convey.ShouldEqual, AuthStatusInvalid)
+			convey.So(r.Ping(ctx), convey.ShouldNotBeNil)
+
+			rec := httptest.NewRecorder()
+			HealthHandler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			convey.So(rec.Code, convey.ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		PatchConvey("test unreachable", func() {
+			r := newRetriever("good")
+			r.config.Endpoint = "http://127.0.0.1:1"
+			status := r.Health(ctx)
+			convey.So(status.Reachable, convey.ShouldBeFalse)
+			convey.So(status.Auth, convey.ShouldEqual, AuthStatusUnknown)
+			convey.So(status.Error, convey.ShouldNotBeEmpty)
+		})
+	})
+}
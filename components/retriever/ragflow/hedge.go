@@ -0,0 +1,152 @@
+package ragflow
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgeMaxExtraLoad = 0.1
+	hedgeLatencyWindow       = 128
+	hedgeMinSamples          = 20
+	// hedgeMaxBudget 限制空闲期积累的对冲额度, 避免流量恢复时集中对冲
+	hedgeMaxBudget = 10
+)
+
+// HedgeConfig 定义了检索请求的对冲策略: 首个请求超过延迟仍未完成时再发出一个相同请求, 先返回者胜出
+type HedgeConfig struct {
+	// Delay 固定的对冲延迟; 配置 Percentile 时作为延迟样本不足时的默认值, 为 0 则样本不足时不对冲
+	Delay time.Duration
+	// Percentile 取最近成功请求延迟的该分位数作为对冲延迟, 取值 (0, 1), 例如 0.95
+	Percentile float64
+	// MaxExtraLoad 对冲请求数与总请求数的最大比例, 默认 0.1
+	MaxExtraLoad float64
+}
+
+type hedger struct {
+	config HedgeConfig
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	budget    float64
+}
+
+func newHedger(config *HedgeConfig) *hedger {
+	if config == nil {
+		return nil
+	}
+	h := &hedger{config: *config}
+	if h.config.MaxExtraLoad <= 0 {
+		h.config.MaxExtraLoad = defaultHedgeMaxExtraLoad
+	}
+	return h
+}
+
+// delay 返回本次请求的对冲延迟, 0 表示不对冲
+func (h *hedger) delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.config.Percentile <= 0 || h.config.Percentile >= 1 || len(h.latencies) < hedgeMinSamples {
+		return h.config.Delay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(math.Ceil(h.config.Percentile*float64(len(sorted)))) - 1
+	return sorted[max(0, idx)]
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeLatencyWindow {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeLatencyWindow
+}
+
+// earn 每个主请求积累 MaxExtraLoad 的对冲额度
+func (h *hedger) earn() {
+	h.mu.Lock()
+	h.budget = math.Min(hedgeMaxBudget, h.budget+h.config.MaxExtraLoad)
+	h.mu.Unlock()
+}
+
+// spend 尝试消耗一次对冲额度
+func (h *hedger) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.budget < 1 {
+		return false
+	}
+	h.budget--
+	return true
+}
+
+type hedgeResult struct {
+	body   []byte
+	header http.Header
+	err    error
+	hedge  bool
+}
+
+// sendHedged 发送请求, 开启对冲时在延迟后补发一个相同请求, 返回最先成功的响应并通过 ctx 取消另一个
+func (r *Retriever) sendHedged(ctx context.Context, method, url string, reqBody []byte) ([]byte, http.Header, error) {
+	if r.hedger == nil {
+		return r.send(ctx, method, url, reqBody)
+	}
+	r.hedger.earn()
+	delay := r.hedger.delay()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgeResult, 2)
+	attempt := func(hedge bool) {
+		start := time.Now()
+		body, header, err := r.send(ctx, method, url, reqBody)
+		if err == nil {
+			r.hedger.observe(time.Since(start))
+		}
+		results <- hedgeResult{body: body, header: header, err: err, hedge: hedge}
+	}
+	go attempt(false)
+
+	var timer <-chan time.Time
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		timer = t.C
+	}
+	inflight := 1
+	var lastErr error
+	for {
+		select {
+		case <-timer:
+			timer = nil
+			if r.hedger.spend() {
+				getCallStats(ctx).setHedged()
+				inflight++
+				go attempt(true)
+			}
+		case res := <-results:
+			inflight--
+			if res.err == nil {
+				if res.hedge {
+					getCallStats(ctx).setHedgeWon()
+				}
+				return res.body, res.header, nil
+			}
+			lastErr = res.err
+			// 对冲请求仍在进行时等待其结果; 首个请求在延迟前就失败时不再对冲
+			if inflight == 0 {
+				return nil, nil, lastErr
+			}
+		}
+	}
+}
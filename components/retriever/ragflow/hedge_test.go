@@ -0,0 +1,81 @@
+package ragflow
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHedge(t *testing.T) {
+	PatchConvey("test hedged requests", t, func() {
+		ctx := context.Background()
+		var hits, canceled int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = io.Copy(io.Discard, req.Body)
+			// 第一个请求很慢, 之后的请求立即返回
+			if atomic.AddInt32(&hits, 1) == 1 {
+				select {
+				case <-req.Context().Done():
+					atomic.AddInt32(&canceled, 1)
+					return
+				case <-time.After(2 * time.Second):
+				}
+			}
+			_, _ = w.Write([]byte(`{"code":0,"data":{"chunks":[{"id":"1","content":"c","similarity":0.9}]}}`))
+		}))
+		defer server.Close()
+
+		newRetriever := func(hedge *HedgeConfig) *Retriever {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "test",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Hedge:      hedge,
+			})
+			convey.So(err, convey.ShouldBeNil)
+			return r
+		}
+
+		PatchConvey("test hedge wins and loser canceled", func() {
+			r := newRetriever(&HedgeConfig{Delay: 20 * time.Millisecond, MaxExtraLoad: 1})
+			cbCtx, rec := newCallbackContext(ctx)
+			start := time.Now()
+			docs, err := r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 1)
+			convey.So(time.Since(start), convey.ShouldBeLessThan, time.Second)
+			convey.So(rec.output.Extra[ExtraKeyHedged], convey.ShouldBeTrue)
+			convey.So(rec.output.Extra[ExtraKeyHedgeWon], convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 2)
+			time.Sleep(20 * time.Millisecond)
+			convey.So(atomic.LoadInt32(&canceled), convey.ShouldEqual, 1)
+		})
+
+		PatchConvey("test extra load cap", func() {
+			r := newRetriever(&HedgeConfig{Delay: 20 * time.Millisecond, MaxExtraLoad: 0.5})
+			cbCtx, rec := newCallbackContext(ctx)
+			cctx, cancel := context.WithTimeout(cbCtx, 100*time.Millisecond)
+			defer cancel()
+			_, err := r.Retrieve(cctx, "q")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 1)
+			convey.So(rec.err, convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test percentile delay", func() {
+			h := newHedger(&HedgeConfig{Delay: time.Second, Percentile: 0.9})
+			convey.So(h.delay(), convey.ShouldEqual, time.Second)
+			for i := 1; i <= 100; i++ {
+				h.observe(time.Duration(i) * time.Millisecond)
+			}
+			convey.So(h.delay(), convey.ShouldEqual, 90*time.Millisecond)
+		})
+	})
+}
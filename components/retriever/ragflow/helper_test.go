@@ -0,0 +1,105 @@
+package ragflow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/retriever"
+)
+
+// callbackRecorder 记录 Retrieve 触发的回调
+type callbackRecorder struct {
+	mu     sync.Mutex
+	input  *retriever.CallbackInput
+	output *retriever.CallbackOutput
+	err    error
+}
+
+func newCallbackContext(ctx context.Context) (context.Context, *callbackRecorder) {
+	rec := &callbackRecorder{}
+	handler := callbacks.NewHandlerBuilder().
+		OnStartFn(func(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			rec.input = retriever.ConvCallbackInput(input)
+			return ctx
+		}).
+		OnEndFn(func(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			rec.output = retriever.ConvCallbackOutput(output)
+			return ctx
+		}).
+		OnErrorFn(func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			rec.err = err
+			return ctx
+		}).
+		Build()
+	return callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Type: typ, Component: components.ComponentOfRetriever}, handler), rec
+}
+
+// retrievalServer 是一个模拟 RAGFlow 检索接口的 httptest.Server, 记录收到的检索请求
+type retrievalServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*request
+	headers  []http.Header
+	respond  func(req *request) *successResponse
+	// apiKey 不为空时, 其他 Key 的请求会收到 RAGFlow 的认证错误
+	apiKey string
+}
+
+func newRetrievalServer(respond func(req *request) *successResponse) *retrievalServer {
+	s := &retrievalServer{respond: respond}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		if httpReq.URL.Path != "/api/v1/retrieval" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		req := &request{}
+		if err := json.NewDecoder(httpReq.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.headers = append(s.headers, httpReq.Header.Clone())
+		apiKey := s.apiKey
+		s.mu.Unlock()
+		if apiKey != "" && httpReq.Header.Get("Authorization") != "Bearer "+apiKey {
+			_, _ = w.Write([]byte(`{"code":109,"message":"Authentication error: API key is invalid!","data":false}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s.respond(req))
+	}))
+	return s
+}
+
+func (s *retrievalServer) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.headers) == 0 {
+		return nil
+	}
+	return s.headers[len(s.headers)-1]
+}
+
+func (s *retrievalServer) received() []*request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*request(nil), s.requests...)
+}
+
+func staticResponse(chunks ...Chunk) func(req *request) *successResponse {
+	return func(req *request) *successResponse {
+		return &successResponse{Data: Data{Chunks: chunks, Total: int64(len(chunks))}}
+	}
+}
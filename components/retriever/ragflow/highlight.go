@@ -0,0 +1,219 @@
+package ragflow
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const highlightsKey = "highlights"
+
+// ContentFormat 决定返回文档 Content 的格式
+type ContentFormat string
+
+const (
+	// ContentFormatRaw 原样返回 Chunk.Content (默认)
+	ContentFormatRaw ContentFormat = ""
+	// ContentFormatPlain 返回去除 HTML 标记并反转义实体后的纯文本, 适合直接拼接到 LLM prompt
+	ContentFormatPlain ContentFormat = "plain"
+	// ContentFormatMarkdown 返回 ContentFormatPlain 的纯文本, 并以 **...** 包裹命中词; 需开启 Highlight, 否则等同于 ContentFormatPlain
+	ContentFormatMarkdown ContentFormat = "markdown"
+)
+
+func (f ContentFormat) valid() bool {
+	switch f {
+	case ContentFormatRaw, ContentFormatPlain, ContentFormatMarkdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// HighlightSpan 描述一次命中词在 Document.Content 中的位置
+type HighlightSpan struct {
+	// Start 与 End 是字节偏移, 满足 Content[Start:End] == Text
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+var (
+	emRegexp      = regexp.MustCompile(`(?is)<em>(.*?)</em>`)
+	htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+)
+
+// sanitize 去除 HTML 标记并反转义实体
+func sanitize(s string) string {
+	return html.UnescapeString(htmlTagRegexp.ReplaceAllString(s, ""))
+}
+
+// highlightTerm 是一个命中词, 以及它在不同格式文本中的字节偏移
+type highlightTerm struct {
+	// raw 为 <em> 内的原始文本, text 为去除 HTML 标记并反转义后的文本
+	raw, text string
+	// rawStart, plainStart 分别是命中词在 parsedHighlight 的 raw, plain 中的位置
+	rawStart, plainStart int
+}
+
+// parsedHighlight 是解析后的高亮文本
+type parsedHighlight struct {
+	// raw 为去掉 <em> 标记的原文, plain 为纯文本
+	raw, plain string
+	terms      []highlightTerm
+}
+
+// parseHighlight 解析 RAGFlow 返回的 <em> 高亮标记.
+// 命中词的位置在遍历高亮文本时同步记录, 而不是事后在内容中查找, 避免命中词出现在其他词内部时定位错误
+func parseHighlight(highlight string) *parsedHighlight {
+	var raw, plain strings.Builder
+	var terms []highlightTerm
+	cursor := 0
+	for _, loc := range emRegexp.FindAllStringSubmatchIndex(highlight, -1) {
+		segment := sanitize(highlight[cursor:loc[0]])
+		raw.WriteString(highlight[cursor:loc[0]])
+		plain.WriteString(segment)
+
+		inner := highlight[loc[2]:loc[3]]
+		term := sanitize(inner)
+		if strings.TrimSpace(term) != "" {
+			terms = append(terms, highlightTerm{
+				raw: inner, text: term,
+				rawStart: raw.Len(), plainStart: plain.Len(),
+			})
+		}
+		raw.WriteString(inner)
+		plain.WriteString(term)
+		cursor = loc[1]
+	}
+	raw.WriteString(highlight[cursor:])
+	plain.WriteString(sanitize(highlight[cursor:]))
+	return &parsedHighlight{raw: raw.String(), plain: plain.String(), terms: terms}
+}
+
+// maxAlignContext 对齐命中词时最多使用的前文长度
+const maxAlignContext = 32
+
+// locateSpans 将命中词从对应格式的高亮文本映射到 content 中.
+// 高亮文本与 content 一致时直接使用记录的位置; 不一致时 (例如高亮只包含片段) 以命中词及其前文在 content 中对齐,
+// 前文逐步缩短, 取离记录位置最近的匹配; 找不到的词会被忽略
+func locateSpans(content string, parsed *parsedHighlight, format ContentFormat) []HighlightSpan {
+	spans := make([]HighlightSpan, 0, len(parsed.terms))
+	for _, term := range parsed.terms {
+		ref, text, start := parsed.plain, term.text, term.plainStart
+		if format == ContentFormatRaw {
+			ref, text, start = parsed.raw, term.raw, term.rawStart
+		}
+		if ref != content {
+			if start = alignTerm(content, ref, text, start); start < 0 {
+				continue
+			}
+		}
+		spans = append(spans, HighlightSpan{Start: start, End: start + len(text), Text: text})
+	}
+	return spans
+}
+
+// alignTerm 返回 ref[start:] 处的 text 在 content 中对应的位置, 找不到时返回 -1
+func alignTerm(content, ref, text string, start int) int {
+	for n := min(start, maxAlignContext); ; n /= 2 {
+		if idx := nearestIndex(content, ref[start-n:start]+text, start-n); idx >= 0 {
+			return idx + n
+		}
+		if n == 0 {
+			return -1
+		}
+	}
+}
+
+// nearestIndex 返回 sub 在 s 中离 pos 最近的出现位置, 不存在时返回 -1
+func nearestIndex(s, sub string, pos int) int {
+	best := -1
+	for offset := 0; offset <= len(s); {
+		idx := strings.Index(s[offset:], sub)
+		if idx < 0 {
+			break
+		}
+		idx += offset
+		if best < 0 || abs(idx-pos) < abs(best-pos) {
+			best = idx
+		}
+		if idx >= pos {
+			break
+		}
+		offset = idx + 1
+	}
+	return best
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// emphasize 在 content 中以 **...** 包裹 spans, 返回新的内容与命中词在其中的位置; 与前一个重叠的 span 会被忽略
+func emphasize(content string, spans []HighlightSpan) (string, []HighlightSpan) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+	var sb strings.Builder
+	shifted := make([]HighlightSpan, 0, len(spans))
+	cursor := 0
+	for _, span := range spans {
+		if span.Start < cursor {
+			continue
+		}
+		sb.WriteString(content[cursor:span.Start])
+		sb.WriteString("**")
+		start := sb.Len()
+		sb.WriteString(span.Text)
+		sb.WriteString("**")
+		shifted = append(shifted, HighlightSpan{Start: start, End: start + len(span.Text), Text: span.Text})
+		cursor = span.End
+	}
+	sb.WriteString(content[cursor:])
+	return sb.String(), shifted
+}
+
+// formatContent 按 format 生成文档内容, 并在开启高亮时计算命中词在结果中的位置.
+// 内容总是由 Chunk.Content 生成: RAGFlow 的高亮只包含命中的句子, 不能代替原文
+func (x *Chunk) formatContent(format ContentFormat) (content string, spans []HighlightSpan) {
+	content = x.Content
+	if format == ContentFormatPlain || format == ContentFormatMarkdown {
+		content = sanitize(x.Content)
+	}
+	if x.Highlight == "" {
+		return content, nil
+	}
+	parsed := parseHighlight(x.Highlight)
+	if len(parsed.terms) == 0 {
+		return content, nil
+	}
+	spans = locateSpans(content, parsed, format)
+	if format == ContentFormatMarkdown {
+		content, spans = emphasize(content, spans)
+	}
+	return content, spans
+}
+
+func setHighlights(doc *schema.Document, spans []HighlightSpan) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[highlightsKey] = spans
+}
+
+// GetHighlights 返回命中词在 doc.Content 中的位置, 仅在开启 Highlight 时存在
+func GetHighlights(doc *schema.Document) []HighlightSpan {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[highlightsKey]; ok {
+		return v.([]HighlightSpan)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ragflow
+
+import (
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHighlight(t *testing.T) {
+	PatchConvey("test highlight", t, func() {
+		chunk := &Chunk{
+			Content:   "RAGFlow is an <b>open-source</b> RAG engine, RAG &amp; agent",
+			Highlight: "<em>RAGFlow</em> is an <b>open-source</b> <em>RAG</em> engine, RAG &amp; agent",
+		}
+
+		PatchConvey("test parse highlight", func() {
+			parsed := parseHighlight(chunk.Highlight)
+			convey.So(parsed.plain, convey.ShouldEqual, "RAGFlow is an open-source RAG engine, RAG & agent")
+			convey.So(parsed.raw, convey.ShouldEqual, chunk.Content)
+			convey.So(len(parsed.terms), convey.ShouldEqual, 2)
+			convey.So(parsed.terms[0].text, convey.ShouldEqual, "RAGFlow")
+			convey.So(parsed.terms[1].text, convey.ShouldEqual, "RAG")
+			convey.So(parsed.plain[parsed.terms[1].plainStart:parsed.terms[1].plainStart+3], convey.ShouldEqual, "RAG")
+		})
+
+		PatchConvey("test raw format", func() {
+			doc := chunk.toDoc(&implOptions{})
+			convey.So(doc.Content, convey.ShouldEqual, chunk.Content)
+			spans := GetHighlights(doc)
+			convey.So(len(spans), convey.ShouldEqual, 2)
+			for _, span := range spans {
+				convey.So(doc.Content[span.Start:span.End], convey.ShouldEqual, span.Text)
+			}
+			convey.So(spans[1].Start, convey.ShouldEqual, 33)
+		})
+
+		PatchConvey("test plain format", func() {
+			doc := chunk.toDoc(&implOptions{ContentFormat: ContentFormatPlain})
+			convey.So(doc.Content, convey.ShouldEqual, "RAGFlow is an open-source RAG engine, RAG & agent")
+			spans := GetHighlights(doc)
+			convey.So(spans, convey.ShouldResemble, []HighlightSpan{
+				{Start: 0, End: 7, Text: "RAGFlow"},
+				{Start: 26, End: 29, Text: "RAG"},
+			})
+		})
+
+		PatchConvey("test markdown format", func() {
+			doc := chunk.toDoc(&implOptions{ContentFormat: ContentFormatMarkdown})
+			convey.So(doc.Content, convey.ShouldEqual, "**RAGFlow** is an open-source **RAG** engine, RAG & agent")
+			for _, span := range GetHighlights(doc) {
+				convey.So(doc.Content[span.Start-2:span.End+2], convey.ShouldEqual, "**"+span.Text+"**")
+			}
+		})
+
+		PatchConvey("test term inside another word", func() {
+			c := &Chunk{Content: "RAGFlow is a RAG engine", Highlight: "RAGFlow is a <em>RAG</em> engine"}
+			for _, format := range []ContentFormat{ContentFormatRaw, ContentFormatPlain} {
+				doc := c.toDoc(&implOptions{ContentFormat: format})
+				convey.So(GetHighlights(doc), convey.ShouldResemble, []HighlightSpan{{Start: 13, End: 16, Text: "RAG"}})
+			}
+			doc := c.toDoc(&implOptions{ContentFormat: ContentFormatMarkdown})
+			convey.So(GetHighlights(doc), convey.ShouldResemble, []HighlightSpan{{Start: 15, End: 18, Text: "RAG"}})
+		})
+
+		PatchConvey("test highlight fragment", func() {
+			c := &Chunk{Content: "RAG first. Later RAGFlow uses RAG again.", Highlight: "Later RAGFlow uses <em>RAG</em> again."}
+			doc := c.toDoc(&implOptions{})
+			convey.So(GetHighlights(doc), convey.ShouldResemble, []HighlightSpan{{Start: 30, End: 33, Text: "RAG"}})
+
+			c = &Chunk{
+				Content:   "# Setup\nInstall <b>RAGFlow</b> first.\n\nThen enable RAG in the settings.",
+				Highlight: "Then enable <em>RAG</em> in the settings.",
+			}
+			doc = c.toDoc(&implOptions{ContentFormat: ContentFormatMarkdown})
+			convey.So(doc.Content, convey.ShouldEqual, "# Setup\nInstall RAGFlow first.\n\nThen enable **RAG** in the settings.")
+			convey.So(GetHighlights(doc), convey.ShouldResemble, []HighlightSpan{{Start: 46, End: 49, Text: "RAG"}})
+		})
+
+		PatchConvey("test raw entity", func() {
+			c := &Chunk{Content: "R&amp;D team", Highlight: "<em>R&amp;D</em> team"}
+			convey.So(GetHighlights(c.toDoc(&implOptions{})), convey.ShouldResemble, []HighlightSpan{{Start: 0, End: 7, Text: "R&amp;D"}})
+			convey.So(GetHighlights(c.toDoc(&implOptions{ContentFormat: ContentFormatPlain})), convey.ShouldResemble, []HighlightSpan{{Start: 0, End: 3, Text: "R&D"}})
+		})
+
+		PatchConvey("test without highlight", func() {
+			doc := (&Chunk{Content: "a <i>b</i>"}).toDoc(&implOptions{ContentFormat: ContentFormatMarkdown})
+			convey.So(doc.Content, convey.ShouldEqual, "a b")
+			convey.So(GetHighlights(doc), convey.ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,152 @@
+package ragflow
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	imageIDKey  = "image_id"
+	imageURLKey = "image_url"
+	imageKey    = "image"
+)
+
+// ImageMode 决定如何处理 Chunk 关联的图片 (图表、扫描页等)
+type ImageMode string
+
+const (
+	// ImageModeNone 仅在 metadata 中记录 ImageID (默认)
+	ImageModeNone ImageMode = ""
+	// ImageModeURL 在 metadata 中记录图片的访问地址, 通过 GetImageURL 获取
+	ImageModeURL ImageMode = "url"
+	// ImageModeBytes 在 metadata 中记录可延迟下载的 *ChunkImage, 通过 GetImage 获取
+	ImageModeBytes ImageMode = "bytes"
+)
+
+func (m ImageMode) valid() bool {
+	switch m {
+	case ImageModeNone, ImageModeURL, ImageModeBytes:
+		return true
+	default:
+		return false
+	}
+}
+
+func getImageURL(endPoint, imageID string) string {
+	return strings.TrimRight(endPoint, "/") + "/v1/document/image/" + url.PathEscape(imageID)
+}
+
+// ChunkImage 是 Chunk 关联的图片, 内容在首次调用 Load 时才会下载, 下载成功后复用结果
+type ChunkImage struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+
+	r        *Retriever
+	mu       sync.Mutex
+	loaded   bool
+	data     []byte
+	mimeType string
+}
+
+// Load 下载并返回图片内容及其 MIME 类型; 失败 (包括 ctx 取消或超时) 不会被缓存, 下次调用会重新下载
+func (x *ChunkImage) Load(ctx context.Context) ([]byte, string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.loaded {
+		return x.data, x.mimeType, nil
+	}
+	body, header, err := x.r.send(ctx, http.MethodGet, x.URL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("load image %s failed: %w", x.ID, err)
+	}
+	x.data = body
+	x.mimeType = header.Get("Content-Type")
+	if x.mimeType == "" || strings.HasPrefix(x.mimeType, "application/octet-stream") {
+		x.mimeType = http.DetectContentType(body)
+	}
+	x.loaded = true
+	return x.data, x.mimeType, nil
+}
+
+func (r *Retriever) setImage(doc *schema.Document, imageID string, mode ImageMode) {
+	if doc == nil || imageID == "" {
+		return
+	}
+	doc.MetaData[imageIDKey] = imageID
+	switch mode {
+	case ImageModeURL:
+		doc.MetaData[imageURLKey] = getImageURL(r.config.Endpoint, imageID)
+	case ImageModeBytes:
+		doc.MetaData[imageKey] = &ChunkImage{
+			ID:  imageID,
+			URL: getImageURL(r.config.Endpoint, imageID),
+			r:   r,
+		}
+	}
+}
+
+func GetImageID(doc *schema.Document) string {
+	if doc == nil {
+		return ""
+	}
+	if v, ok := doc.MetaData[imageIDKey]; ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func GetImageURL(doc *schema.Document) string {
+	if doc == nil {
+		return ""
+	}
+	if v, ok := doc.MetaData[imageURLKey]; ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func GetImage(doc *schema.Document) *ChunkImage {
+	if doc == nil {
+		return nil
+	}
+	if v, ok := doc.MetaData[imageKey]; ok {
+		return v.(*ChunkImage)
+	}
+	return nil
+}
+
+// ToImageParts 将携带图片的文档转换为多模态模型可用的图片消息片段, 没有图片的文档会被跳过.
+// ImageModeBytes 的图片会被下载并以 RFC-2397 data URL 的形式内嵌
+func ToImageParts(ctx context.Context, docs []*schema.Document) ([]schema.ChatMessagePart, error) {
+	parts := make([]schema.ChatMessagePart, 0, len(docs))
+	for _, doc := range docs {
+		if img := GetImage(doc); img != nil {
+			data, mimeType, err := img.Load(ctx)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, schema.ChatMessagePart{
+				Type: schema.ChatMessagePartTypeImageURL,
+				ImageURL: &schema.ChatMessageImageURL{
+					URL:      fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+					MIMEType: mimeType,
+				},
+			})
+			continue
+		}
+		if u := GetImageURL(doc); u != "" {
+			parts = append(parts, schema.ChatMessagePart{
+				Type:     schema.ChatMessagePartTypeImageURL,
+				ImageURL: &schema.ChatMessageImageURL{URL: u},
+			})
+		}
+	}
+	return parts, nil
+}
@@ -0,0 +1,86 @@
+package ragflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestImage(t *testing.T) {
+	PatchConvey("test image", t, func() {
+		ctx := context.Background()
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			if req.URL.Path != "/v1/document/image/kb-img1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("png-bytes"))
+		}))
+		defer server.Close()
+
+		r := &Retriever{
+			config: &RetrieverConfig{Endpoint: server.URL},
+			client: server.Client(),
+		}
+
+		PatchConvey("test none mode", func() {
+			doc := &schema.Document{MetaData: map[string]any{}}
+			r.setImage(doc, "kb-img1", ImageModeNone)
+			convey.So(GetImageID(doc), convey.ShouldEqual, "kb-img1")
+			convey.So(GetImageURL(doc), convey.ShouldBeEmpty)
+			convey.So(GetImage(doc), convey.ShouldBeNil)
+		})
+
+		PatchConvey("test url mode", func() {
+			doc := &schema.Document{MetaData: map[string]any{}}
+			r.setImage(doc, "kb-img1", ImageModeURL)
+			convey.So(GetImageURL(doc), convey.ShouldEqual, server.URL+"/v1/document/image/kb-img1")
+
+			parts, err := ToImageParts(ctx, []*schema.Document{doc, {MetaData: map[string]any{}}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(parts), convey.ShouldEqual, 1)
+			convey.So(parts[0].ImageURL.URL, convey.ShouldEqual, GetImageURL(doc))
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 0)
+		})
+
+		PatchConvey("test bytes mode", func() {
+			doc := &schema.Document{MetaData: map[string]any{}}
+			r.setImage(doc, "kb-img1", ImageModeBytes)
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 0)
+
+			data, mimeType, err := GetImage(doc).Load(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, "png-bytes")
+			convey.So(mimeType, convey.ShouldEqual, "image/png")
+
+			parts, err := ToImageParts(ctx, []*schema.Document{doc})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(parts[0].ImageURL.URL, convey.ShouldEqual, "data:image/png;base64,cG5nLWJ5dGVz")
+			convey.So(parts[0].ImageURL.MIMEType, convey.ShouldEqual, "image/png")
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 1)
+		})
+
+		PatchConvey("test failed load is not cached", func() {
+			doc := &schema.Document{MetaData: map[string]any{}}
+			r.setImage(doc, "kb-img1", ImageModeBytes)
+
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			_, _, err := GetImage(doc).Load(canceled)
+			convey.So(err, convey.ShouldNotBeNil)
+
+			data, _, err := GetImage(doc).Load(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, "png-bytes")
+		})
+	})
+}
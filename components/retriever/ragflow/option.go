@@ -0,0 +1,221 @@
+package ragflow
+
+import (
+	"fmt"
+
+	"github.com/cloudwego/eino/components/retriever"
+)
+
+// implOptions 是 RAGFlow Retriever 的专有调用选项, 默认值取自 RetrieverConfig
+type implOptions struct {
+	ContentFormat ContentFormat
+	ImageMode     ImageMode
+
+	ContextExpansion  *ContextExpansion
+	DocumentCollapse  *DocumentCollapse
+	DatasetIDs        []string
+	PageSize          int
+	Routing           *RoutingConfig
+	TwoStage          *TwoStage
+	Feedback          *Feedback
+	Score             *ScoreConfig
+	AdaptiveThreshold *AdaptiveThreshold
+	Rerank            *RerankConfig
+	Diversification   *Diversification
+
+	MaxTokens     int
+	TruncateToFit bool
+
+	APIKey  string
+	Headers map[string]string
+
+	QueryTransformers []QueryTransformer
+	PostProcessors    []PostProcessor
+}
+
+func (r *Retriever) getImplOptions(opts ...retriever.Option) *implOptions {
+	return retriever.GetImplSpecificOptions(&implOptions{
+		ContentFormat: r.config.ContentFormat,
+		ImageMode:     r.config.ImageMode,
+
+		ContextExpansion:  r.config.ContextExpansion,
+		DocumentCollapse:  r.config.DocumentCollapse,
+		Routing:           r.config.Routing,
+		TwoStage:          r.config.TwoStage,
+		Feedback:          r.config.Feedback,
+		Score:             r.config.Score,
+		AdaptiveThreshold: r.config.AdaptiveThreshold,
+		Rerank:            r.config.Rerank,
+		Diversification:   r.config.Diversification,
+
+		MaxTokens:     r.config.MaxTokens,
+		TruncateToFit: r.config.TruncateToFit,
+
+		QueryTransformers: r.config.QueryTransformers,
+		PostProcessors:    r.config.PostProcessors,
+	}, opts...)
+}
+
+func (o *implOptions) validate() error {
+	if !o.ContentFormat.valid() {
+		return fmt.Errorf("unknown content_format: %s", o.ContentFormat)
+	}
+	if !o.ImageMode.valid() {
+		return fmt.Errorf("unknown image_mode: %s", o.ImageMode)
+	}
+	if err := o.Score.validate(); err != nil {
+		return err
+	}
+	if err := o.AdaptiveThreshold.validate(); err != nil {
+		return err
+	}
+	if o.Routing != nil && o.Routing.Router == nil {
+		return fmt.Errorf("router is required")
+	}
+	if o.Rerank != nil && o.Rerank.Reranker == nil {
+		return fmt.Errorf("reranker is required")
+	}
+	if err := validateHeaders(o.Headers); err != nil {
+		return err
+	}
+	if o.ContextExpansion != nil && !o.ContextExpansion.Mode.valid() {
+		return fmt.Errorf("unknown expansion mode: %s", o.ContextExpansion.Mode)
+	}
+	if o.DocumentCollapse != nil && !o.DocumentCollapse.Score.valid() {
+		return fmt.Errorf("unknown collapse score: %s", o.DocumentCollapse.Score)
+	}
+	return nil
+}
+
+// WithContentFormat 设置本次调用返回文档 Content 的格式, 覆盖 RetrieverConfig.ContentFormat
+func WithContentFormat(format ContentFormat) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.ContentFormat = format
+	})
+}
+
+// WithImageMode 设置本次调用对 Chunk 图片的处理方式, 覆盖 RetrieverConfig.ImageMode
+func WithImageMode(mode ImageMode) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.ImageMode = mode
+	})
+}
+
+// WithContextExpansion 设置本次调用的相邻 chunk 扩展配置, 覆盖 RetrieverConfig.ContextExpansion; 传入 nil 关闭扩展
+func WithContextExpansion(expansion *ContextExpansion) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.ContextExpansion = expansion
+	})
+}
+
+// WithDocumentCollapse 设置本次调用的文档折叠配置, 覆盖 RetrieverConfig.DocumentCollapse; 传入 nil 关闭折叠
+func WithDocumentCollapse(collapse *DocumentCollapse) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.DocumentCollapse = collapse
+	})
+}
+
+// WithMaxTokens 设置本次调用返回文档的总 token 预算, 覆盖 RetrieverConfig.MaxTokens
+func WithMaxTokens(maxTokens int) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.MaxTokens = maxTokens
+	})
+}
+
+// WithTruncateToFit 设置本次调用是否截断第一个超出预算的文档, 覆盖 RetrieverConfig.TruncateToFit
+func WithTruncateToFit(truncate bool) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.TruncateToFit = truncate
+	})
+}
+
+// WithAPIKey 使用指定的 API Key 完成本次调用的全部请求, 适用于多租户代理; 该 Key 认证失败时不会刷新重试
+func WithAPIKey(apiKey string) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.APIKey = apiKey
+	})
+}
+
+// WithHeaders 为本次调用的全部请求添加请求头, 优先于 RetrieverConfig.Headers 与 RetrieverConfig.HeaderFunc
+func WithHeaders(headers map[string]string) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Headers = headers
+	})
+}
+
+// WithQueryTransformers 设置本次调用的查询处理链, 覆盖 RetrieverConfig.QueryTransformers; 不传参数时关闭查询处理
+func WithQueryTransformers(transformers ...QueryTransformer) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.QueryTransformers = transformers
+	})
+}
+
+// WithPostProcessors 设置本次调用的结果后处理链, 覆盖 RetrieverConfig.PostProcessors; 不传参数时关闭后处理
+func WithPostProcessors(processors ...PostProcessor) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.PostProcessors = processors
+	})
+}
+
+// WithRerank 设置本次调用的客户端重排配置, 覆盖 RetrieverConfig.Rerank; 传入 nil 关闭重排
+func WithRerank(rerank *RerankConfig) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Rerank = rerank
+	})
+}
+
+// WithDiversification 设置本次调用的结果多样化配置, 覆盖 RetrieverConfig.Diversification; 传入 nil 关闭多样化
+func WithDiversification(diversification *Diversification) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Diversification = diversification
+	})
+}
+
+// WithScoreConfig 设置本次调用的得分计算方式, 覆盖 RetrieverConfig.Score; 传入 nil 使用综合相似度
+func WithScoreConfig(score *ScoreConfig) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Score = score
+	})
+}
+
+// WithAdaptiveThreshold 设置本次调用的自适应阈值, 覆盖 RetrieverConfig.AdaptiveThreshold; 传入 nil 使用固定阈值
+func WithAdaptiveThreshold(adaptive *AdaptiveThreshold) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.AdaptiveThreshold = adaptive
+	})
+}
+
+// WithTwoStage 设置本次调用的两阶段检索配置, 覆盖 RetrieverConfig.TwoStage; 传入 nil 只检索一次
+func WithTwoStage(twoStage *TwoStage) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.TwoStage = twoStage
+	})
+}
+
+// WithFeedback 设置本次调用的关键词反馈配置, 覆盖 RetrieverConfig.Feedback; 传入 nil 关闭反馈
+func WithFeedback(feedback *Feedback) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Feedback = feedback
+	})
+}
+
+// WithRouting 设置本次调用的数据集路由配置, 覆盖 RetrieverConfig.Routing; 传入 nil 检索全部数据集
+func WithRouting(routing *RoutingConfig) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.Routing = routing
+	})
+}
+
+// WithPageSize 设置本次调用最多返回的 chunk 数 (RAGFlow page_size), 不改变参与向量计算的候选数 top_k
+func WithPageSize(pageSize int) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.PageSize = pageSize
+	})
+}
+
+// WithDatasetIDs 将本次调用的检索范围限制在指定的数据集内, 只能选择已配置的数据集, 指定后不再路由
+func WithDatasetIDs(ids ...string) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *implOptions) {
+		o.DatasetIDs = ids
+	})
+}
@@ -0,0 +1,130 @@
+package ragflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// PostProcessor 对检索结果做后处理, 多个 PostProcessor 按顺序串联执行, 可以过滤、修改或重新排序文档
+type PostProcessor func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error)
+
+func postProcess(ctx context.Context, docs []*schema.Document, processors []PostProcessor) ([]*schema.Document, error) {
+	var err error
+	for i, p := range processors {
+		if docs, err = p(ctx, docs); err != nil {
+			return nil, fmt.Errorf("post processor %d failed: %w", i, err)
+		}
+	}
+	return docs, nil
+}
+
+// filterDocs 保留 keep 返回 true 的文档
+func filterDocs(docs []*schema.Document, keep func(doc *schema.Document) bool) []*schema.Document {
+	kept := make([]*schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		if keep(doc) {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
+
+// DedupByContent 按内容哈希去重, 内容比较前会合并空白; 重复时保留得分最高的文档, 位置取其首次出现的位置
+func DedupByContent() PostProcessor {
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		index := make(map[[sha256.Size]byte]int, len(docs))
+		deduped := make([]*schema.Document, 0, len(docs))
+		for _, doc := range docs {
+			sum := sha256.Sum256([]byte(strings.Join(strings.Fields(doc.Content), " ")))
+			if i, ok := index[sum]; ok {
+				if doc.Score() > deduped[i].Score() {
+					deduped[i] = doc
+				}
+				continue
+			}
+			index[sum] = len(deduped)
+			deduped = append(deduped, doc)
+		}
+		return deduped, nil
+	}
+}
+
+// MinContentLength 过滤掉去除首尾空白后少于 minRunes 个字符的文档
+func MinContentLength(minRunes int) PostProcessor {
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		return filterDocs(docs, func(doc *schema.Document) bool {
+			return utf8.RuneCountInString(strings.TrimSpace(doc.Content)) >= minRunes
+		}), nil
+	}
+}
+
+// ExcludeByRegex 过滤掉内容与任一 pattern 匹配的文档
+func ExcludeByRegex(patterns ...*regexp.Regexp) PostProcessor {
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		return filterDocs(docs, func(doc *schema.Document) bool {
+			for _, p := range patterns {
+				if p.MatchString(doc.Content) {
+					return false
+				}
+			}
+			return true
+		}), nil
+	}
+}
+
+// ExcludeByMetadata 过滤掉 MetaData[key] 等于任一 values 的文档, 值按 fmt.Sprint 的结果比较
+func ExcludeByMetadata(key string, values ...any) PostProcessor {
+	excluded := make(map[string]bool, len(values))
+	for _, v := range values {
+		excluded[fmt.Sprint(v)] = true
+	}
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		return filterDocs(docs, func(doc *schema.Document) bool {
+			v, ok := doc.MetaData[key]
+			return !ok || !excluded[fmt.Sprint(v)]
+		}), nil
+	}
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// CleanContent 清理文档内容: 删除控制字符, 去掉行尾空白, 将连续的空行合并为一个, 并去除首尾空白.
+// 清理后内容为空的文档会被过滤; 该处理会改变内容长度, 之后 GetHighlights 的偏移不再准确
+func CleanContent() PostProcessor {
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		for _, doc := range docs {
+			content := strings.Map(func(r rune) rune {
+				if unicode.IsControl(r) && r != '\n' && r != '\t' {
+					return -1
+				}
+				return r
+			}, doc.Content)
+			lines := strings.Split(content, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+			}
+			doc.Content = strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+		}
+		return filterDocs(docs, func(doc *schema.Document) bool {
+			return doc.Content != ""
+		}), nil
+	}
+}
+
+// SortByScore 按得分从高到低重新排序, 得分相同的文档保持原有顺序
+func SortByScore() PostProcessor {
+	return func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return docs[i].Score() > docs[j].Score()
+		})
+		return docs, nil
+	}
+}
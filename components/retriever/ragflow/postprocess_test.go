@@ -0,0 +1,98 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func scoredDoc(id, content string, score float64) *schema.Document {
+	return (&schema.Document{ID: id, Content: content, MetaData: map[string]any{}}).WithScore(score)
+}
+
+func docIDs(docs []*schema.Document) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+func TestPostProcessor(t *testing.T) {
+	PatchConvey("test PostProcessor", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test built-in processors", func() {
+			docs, err := postProcess(ctx, []*schema.Document{
+				scoredDoc("a", "hello  world", 0.5),
+				scoredDoc("b", "other", 0.6),
+				scoredDoc("c", "hello world\n", 0.9),
+			}, []PostProcessor{DedupByContent()})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"c", "b"})
+
+			docs, _ = postProcess(ctx, []*schema.Document{
+				scoredDoc("a", " 短 ", 0.5),
+				scoredDoc("b", "足够长的内容", 0.6),
+			}, []PostProcessor{MinContentLength(3)})
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"b"})
+
+			x := scoredDoc("x", "see the table of contents", 0.5)
+			y := scoredDoc("y", "real content", 0.5)
+			z := scoredDoc("z", "draft content", 0.5)
+			z.MetaData[origDocNameKey] = "draft.md"
+			docs, _ = postProcess(ctx, []*schema.Document{x, y, z}, []PostProcessor{
+				ExcludeByRegex(regexp.MustCompile(`(?i)table of contents`)),
+				ExcludeByMetadata(origDocNameKey, "draft.md"),
+			})
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"y"})
+
+			docs, _ = postProcess(ctx, []*schema.Document{
+				scoredDoc("a", "  line1  \x00\n\n\n\nline2\t \n", 0.5),
+				scoredDoc("b", " \x07 ", 0.6),
+			}, []PostProcessor{CleanContent()})
+			convey.So(len(docs), convey.ShouldEqual, 1)
+			convey.So(docs[0].Content, convey.ShouldEqual, "line1\n\nline2")
+
+			docs, _ = postProcess(ctx, []*schema.Document{
+				scoredDoc("a", "a", 0.5),
+				scoredDoc("b", "b", 0.9),
+				scoredDoc("c", "c", 0.5),
+			}, []PostProcessor{SortByScore()})
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"b", "a", "c"})
+		})
+
+		PatchConvey("test retrieve with processors", func() {
+			server := newRetrievalServer(staticResponse(
+				Chunk{ID: "1", DocumentID: "1", Content: "same", Similarity: 0.7},
+				Chunk{ID: "2", DocumentID: "2", Content: "same", Similarity: 0.8},
+				Chunk{ID: "3", DocumentID: "3", Content: "x", Similarity: 0.9},
+			))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:         "key",
+				Endpoint:       server.URL,
+				DatasetIDs:     []string{"kb1"},
+				PostProcessors: []PostProcessor{DedupByContent(), MinContentLength(2)},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"2"})
+
+			docs, err = r.Retrieve(ctx, "q", WithPostProcessors(SortByScore()))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"3", "2", "1"})
+
+			_, err = r.Retrieve(ctx, "q", WithPostProcessors(func(ctx context.Context, docs []*schema.Document) ([]*schema.Document, error) {
+				return nil, errors.New("boom")
+			}))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,169 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// ExtraKeyOriginalQuery 调用方传入的原始查询 (string), 位于 retriever.CallbackInput.Extra
+	ExtraKeyOriginalQuery = "original_query"
+	// ExtraKeyFinalQuery 经过 QueryTransformer 处理后实际发送给 RAGFlow 的查询 (string), 位于 retriever.CallbackInput.Extra
+	ExtraKeyFinalQuery = "final_query"
+)
+
+// QueryTransformer 在查询发送给 RAGFlow 之前对其进行处理, 多个 QueryTransformer 按顺序串联执行
+type QueryTransformer interface {
+	Transform(ctx context.Context, query string) (string, error)
+}
+
+// QueryTransformerFunc 将普通函数适配为 QueryTransformer
+type QueryTransformerFunc func(ctx context.Context, query string) (string, error)
+
+func (f QueryTransformerFunc) Transform(ctx context.Context, query string) (string, error) {
+	return f(ctx, query)
+}
+
+// transformQuery 依次执行 transformers, 结果为空时返回错误
+func transformQuery(ctx context.Context, query string, transformers []QueryTransformer) (string, error) {
+	var err error
+	for i, t := range transformers {
+		if query, err = t.Transform(ctx, query); err != nil {
+			return "", fmt.Errorf("query transformer %d failed: %w", i, err)
+		}
+	}
+	if len(transformers) > 0 && strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is empty after transformation")
+	}
+	return query, nil
+}
+
+// NormalizeWhitespace 去除首尾空白, 并将连续的空白字符合并为一个空格
+func NormalizeWhitespace() QueryTransformer {
+	return QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+		return strings.Join(strings.Fields(query), " "), nil
+	})
+}
+
+// DefaultBoilerplate 是 StripBoilerplate 未指定模式时使用的常见对话客套语
+var DefaultBoilerplate = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*(hi|hello|hey)[,!.\s]+`),
+	regexp.MustCompile(`(?i)^\s*(please\s+)?(can|could|would) you\s+(please\s+)?(tell me|help me( to)?|explain)?\s*`),
+	regexp.MustCompile(`(?i)^\s*please\s+`),
+	regexp.MustCompile(`(?i)[,.\s]*(thanks|thank you)( in advance)?[!.\s]*$`),
+	regexp.MustCompile(`^\s*(你好|您好)[,，!！\s]*`),
+	regexp.MustCompile(`^\s*(请问|请帮我|帮我|麻烦)(一下)?[,，\s]*`),
+	regexp.MustCompile(`[,，。\s]*(谢谢|多谢)[!！。\s]*$`),
+}
+
+// StripBoilerplate 删除与 patterns 匹配的内容, 用于去掉对话中与检索无关的客套语; patterns 为空时使用 DefaultBoilerplate
+func StripBoilerplate(patterns ...*regexp.Regexp) QueryTransformer {
+	if len(patterns) == 0 {
+		patterns = DefaultBoilerplate
+	}
+	return QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+		for _, p := range patterns {
+			query = p.ReplaceAllString(query, "")
+		}
+		return strings.TrimSpace(query), nil
+	})
+}
+
+// MaxQueryLength 将查询截断到最多 maxRunes 个字符, 尽量在空白处截断以保留完整的词
+func MaxQueryLength(maxRunes int) QueryTransformer {
+	return QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+		if maxRunes <= 0 || utf8.RuneCountInString(query) <= maxRunes {
+			return query, nil
+		}
+		all := []rune(query)
+		runes := all[:maxRunes]
+		// 截断点落在单词中间时回退到上一个空白
+		if !unicode.IsSpace(all[maxRunes]) {
+			for i := len(runes) - 1; i > 0; i-- {
+				if unicode.IsSpace(runes[i]) {
+					runes = runes[:i]
+					break
+				}
+			}
+		}
+		return strings.TrimSpace(string(runes)), nil
+	})
+}
+
+// SynonymRewriter 按同义词表替换查询中的词, 英文按单词边界忽略大小写匹配, 其他文本按子串匹配
+func SynonymRewriter(synonyms map[string]string) QueryTransformer {
+	type rule struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}
+	// 长词优先, 避免短词先替换破坏长词
+	terms := make([]string, 0, len(synonyms))
+	for term := range synonyms {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+	rules := make([]rule, 0, len(terms))
+	for _, term := range terms {
+		expr := regexp.QuoteMeta(term)
+		if isASCIIWord(term) {
+			expr = `(?i)\b` + expr + `\b`
+		}
+		rules = append(rules, rule{pattern: regexp.MustCompile(expr), replacement: synonyms[term]})
+	}
+	return QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+		for _, r := range rules {
+			query = r.pattern.ReplaceAllLiteralString(query, r.replacement)
+		}
+		return query, nil
+	})
+}
+
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultRewritePrompt 是 ChatModelRewriter 默认使用的系统提示
+const defaultRewritePrompt = "You rewrite user questions into concise search queries for a knowledge base. " +
+	"Keep the original language, keep all key entities and constraints, remove chit-chat. " +
+	"Reply with the rewritten query only."
+
+// ChatModelRewriter 使用大模型改写查询, prompt 为空时使用内置的系统提示; 模型返回空内容时保留原查询
+func ChatModelRewriter(chatModel model.BaseChatModel, prompt string) QueryTransformer {
+	if prompt == "" {
+		prompt = defaultRewritePrompt
+	}
+	return QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+		msg, err := chatModel.Generate(ctx, []*schema.Message{
+			schema.SystemMessage(prompt),
+			schema.UserMessage(query),
+		})
+		if err != nil {
+			return "", fmt.Errorf("rewrite query failed: %w", err)
+		}
+		rewritten := strings.TrimSpace(msg.Content)
+		if rewritten == "" {
+			return query, nil
+		}
+		return rewritten, nil
+	})
+}
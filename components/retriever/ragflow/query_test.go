@@ -0,0 +1,97 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type rewriteModel struct {
+	content string
+	input   []*schema.Message
+}
+
+func (m *rewriteModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.input = input
+	return schema.AssistantMessage(m.content, nil), nil
+}
+
+func (m *rewriteModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestQueryTransformer(t *testing.T) {
+	PatchConvey("test QueryTransformer", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test built-in transformers", func() {
+			q, err := transformQuery(ctx, "  Hello,  could you please tell me \n how to   reset password? Thanks!", []QueryTransformer{
+				NormalizeWhitespace(),
+				StripBoilerplate(),
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(q, convey.ShouldEqual, "how to reset password?")
+
+			q, err = transformQuery(ctx, "请问, 如何重置密码。谢谢!", []QueryTransformer{StripBoilerplate()})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(q, convey.ShouldEqual, "如何重置密码")
+
+			q, _ = transformQuery(ctx, "reset the password quickly", []QueryTransformer{MaxQueryLength(12)})
+			convey.So(q, convey.ShouldEqual, "reset the")
+			q, _ = transformQuery(ctx, "重置密码的步骤", []QueryTransformer{MaxQueryLength(4)})
+			convey.So(q, convey.ShouldEqual, "重置密码")
+
+			q, _ = transformQuery(ctx, "PWD reset for k8s, pwdx", []QueryTransformer{SynonymRewriter(map[string]string{
+				"pwd": "password",
+				"k8s": "kubernetes",
+			})})
+			convey.So(q, convey.ShouldEqual, "password reset for kubernetes, pwdx")
+
+			cm := &rewriteModel{content: " password reset steps \n"}
+			q, err = transformQuery(ctx, "how do I reset my pwd", []QueryTransformer{ChatModelRewriter(cm, "")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(q, convey.ShouldEqual, "password reset steps")
+			convey.So(cm.input[1].Content, convey.ShouldEqual, "how do I reset my pwd")
+
+			_, err = transformQuery(ctx, "thanks", []QueryTransformer{StripBoilerplate()})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test retrieve with transformers", func() {
+			server := newRetrievalServer(staticResponse(Chunk{ID: "1", Content: "c", Similarity: 0.9}))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:            "key",
+				Endpoint:          server.URL,
+				DatasetIDs:        []string{"kb1"},
+				QueryTransformers: []QueryTransformer{NormalizeWhitespace(), StripBoilerplate()},
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			cbCtx, rec := newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, " please  reset   password ")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(server.received()[0].Question, convey.ShouldEqual, "reset password")
+			convey.So(rec.input.Extra[ExtraKeyOriginalQuery], convey.ShouldEqual, " please  reset   password ")
+			convey.So(rec.input.Extra[ExtraKeyFinalQuery], convey.ShouldEqual, "reset password")
+
+			_, err = r.Retrieve(ctx, " please  reset ", WithQueryTransformers())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(server.received()[1].Question, convey.ShouldEqual, " please  reset ")
+
+			failing := QueryTransformerFunc(func(ctx context.Context, query string) (string, error) {
+				return "", errors.New("boom")
+			})
+			cbCtx, rec = newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, "q", WithQueryTransformers(failing))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(rec.err, convey.ShouldNotBeNil)
+			convey.So(len(server.received()), convey.ShouldEqual, 2)
+		})
+	})
+}
@@ -1,21 +1,24 @@
 package ragflow
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/eino/components/retriever"
 	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log"
 	"net/http"
-	"strings"
 )
 
 const (
 	origDocIDKey   = "orig_doc_id"
 	origDocNameKey = "orig_doc_name"
 	keywordsKey    = "keywords"
+	chunkIDKey     = "chunk_id"
+	datasetIDKey   = "dataset_id"
 )
 
 type RetrievalRequestOption struct {
@@ -94,6 +97,19 @@ type request struct {
 	//RetrievalModel *RetrievalModel `json:"retrieval_model,omitempty"`
 }
 
+// HTTPError 是 RAGFlow 返回非 200 状态码时的错误
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("request failed: %s", e.Message)
+	}
+	return fmt.Sprintf("request failed with status code: %d", e.StatusCode)
+}
+
 type errorResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -169,36 +185,110 @@ type successResponse struct {
 	Data Data `json:"data"`
 }
 
-func (r *Retriever) getRequest(query string, option *retriever.Options) *request {
+// getRequest 构造检索请求, pageSize 大于 0 时覆盖配置中的 PageSize, 用于过采样
+func (r *Retriever) getRequest(query string, option *retriever.Options, target *searchTarget, pageSize int) *request {
 	// 避免污染原始数据，这里必须copy一次
 	rm := r.config.RetrievalRequestOption.copy()
 
 	// options 配置优先
 	rm.TopK = option.TopK
 	rm.SimilarityThreshold = option.ScoreThreshold
+	if pageSize > 0 {
+		rm.PageSize = &pageSize
+		// 参与向量计算的 chunk 数不能少于返回数量
+		if rm.TopK != nil && *rm.TopK < pageSize {
+			rm.TopK = ptrOf(pageSize)
+		}
+	}
 	return &request{
 		Question:               query,
-		DatasetIDs:             r.config.DatasetIDs,
-		DocumentIDs:            r.config.DocumentIDs,
+		DatasetIDs:             target.DatasetIDs,
+		DocumentIDs:            target.DocumentIDs,
 		RetrievalRequestOption: rm,
 	}
 }
 
-func (r *Retriever) doPost(ctx context.Context, query string, option *retriever.Options) (res *successResponse, err error) {
-	reqData, err := sonic.MarshalString(r.getRequest(query, option))
+func (r *Retriever) doPost(ctx context.Context, query string, option *retriever.Options, target *searchTarget, pageSize int) (res *successResponse, err error) {
+	reqData, err := sonic.MarshalString(r.getRequest(query, option, target, pageSize))
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling data: %w", err)
 	}
+	// 熔断器打开时快速失败
+	probe, err := r.breaker.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.breaker.record(ctx, probe, err)
+	}()
 	// 发送检索请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.retrieverURL, strings.NewReader(reqData))
+	body, _, err := r.sendHedged(ctx, http.MethodPost, r.retrieverURL, []byte(reqData))
+	if err != nil {
+		return nil, err
+	}
+	status := &apiResponse{}
+	if err = sonic.Unmarshal(body, status); err == nil && status.Code != 0 {
+		return nil, &APIError{Code: status.Code, Message: status.Message}
+	}
+	res = &successResponse{}
+	if err = sonic.Unmarshal(body, res); err != nil {
+		return nil, fmt.Errorf("decode response failed: %w", err)
+	}
+
+	return res, nil
+}
+
+// send 发送 HTTP 请求并读取响应, 非 200 状态码会被转换为 error.
+// 使用 CredentialProvider 时, 认证失败会强制刷新一次凭证后重试
+func (r *Retriever) send(ctx context.Context, method, url string, reqBody []byte) (body []byte, header http.Header, err error) {
+	ctx, span := r.tracer().Start(ctx, "ragflow.http "+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrHTTPMethod.String(method), attrURL.String(url)))
+	retries := 0
+	defer func() {
+		span.SetAttributes(attrRetryCount.Int(retries))
+		span.SetAttributes(responseAttributes(body, err)...)
+		endSpan(span, err)
+	}()
+
+	authorization, refreshable, err := r.getAuthorization(ctx, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, header, err = r.sendOnce(ctx, method, url, reqBody, authorization)
+	if !refreshable || !isAuthFailure(body, err) {
+		return body, header, err
+	}
+	retries++
+	if authorization, _, err = r.getAuthorization(ctx, true); err != nil {
+		return nil, nil, err
+	}
+	return r.sendOnce(ctx, method, url, reqBody, authorization)
+}
+
+func (r *Retriever) sendOnce(ctx context.Context, method, url string, reqBody []byte, authorization string) (body []byte, header http.Header, err error) {
+	var reader io.Reader
+	if reqBody != nil {
+		reader = bytes.NewReader(reqBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
 	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+		return nil, nil, fmt.Errorf("create request failed: %w", err)
+	}
+	release, wait, err := r.rateLimiter(ctx).Acquire(ctx)
+	getCallStats(ctx).addRateLimitWait(wait)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errRateLimitWait, err)
+	}
+	defer release()
+	r.setHeaders(ctx, req)
+	r.injectTraceContext(ctx, req)
+	req.Header.Set("Authorization", authorization)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Set("Authorization", r.authorization)
-	req.Header.Set("Content-Type", "application/json")
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("do request failed: %w", err)
+		return nil, nil, fmt.Errorf("do request failed: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -206,25 +296,20 @@ func (r *Retriever) doPost(ctx context.Context, query string, option *retriever.
 			log.Printf("[Error]failed to close response body:%v", err)
 		}
 	}(resp.Body)
-	var body []byte
 	body, err = io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	// 请求失败
 	if resp.StatusCode != http.StatusOK {
 		errResp := &errorResponse{}
+		httpErr := &HTTPError{StatusCode: resp.StatusCode}
 		if err = sonic.Unmarshal(body, errResp); err == nil && errResp.Message != "" {
-			return nil, fmt.Errorf("request failed: %s", errResp.Message)
+			httpErr.Message = errResp.Message
 		}
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
-	res = &successResponse{}
-	if err = sonic.Unmarshal(body, res); err != nil {
-		return nil, fmt.Errorf("decode response failed: %w", err)
+		return nil, nil, httpErr
 	}
-
-	return res, nil
+	return body, resp.Header, nil
 }
 
 //	func (x *Record) toDoc() *schema.Document {
@@ -244,22 +329,31 @@ func (r *Retriever) doPost(ctx context.Context, query string, option *retriever.
 //		}
 //		return doc
 //	}
-func (x *Chunk) toDoc() *schema.Document {
+func (x *Chunk) toDoc(opt *implOptions) *schema.Document {
 	if x == nil {
 		return nil
 	}
+	content, spans := x.formatContent(opt.ContentFormat)
 	doc := &schema.Document{
 		ID:       x.DocumentID,
-		Content:  x.Content,
+		Content:  content,
 		MetaData: map[string]any{},
 	}
-	doc.WithScore(x.Similarity) //待定
+	// 默认使用综合相似度, 配置 ScoreConfig 时由 Retrieve 重新计算
+	doc.WithScore(x.Similarity)
+	setSimilarities(doc, x)
 	setOrgDocID(doc, x.DocumentID)
+	setChunkID(doc, x.ID)
+	setDatasetID(doc, x.KbID)
 	setKeywords(doc, x.ImportantKeywords)
+	setPositions(doc, x.Positions)
 	//if x.Document != nil {
 	//	setOrgDocName(doc, x.Document.Name)
 	//}
 	setOrgDocName(doc, x.DocumentKeyWord)
+	if spans != nil {
+		setHighlights(doc, spans)
+	}
 	return doc
 }
 
@@ -277,6 +371,20 @@ func setOrgDocName(doc *schema.Document, name string) {
 	doc.MetaData[origDocNameKey] = name
 }
 
+func setChunkID(doc *schema.Document, id string) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[chunkIDKey] = id
+}
+
+func setDatasetID(doc *schema.Document, id string) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[datasetIDKey] = id
+}
+
 func setKeywords(doc *schema.Document, keywords []string) {
 	if doc == nil {
 		return
@@ -304,6 +412,26 @@ func GetOrgDocName(doc *schema.Document) string {
 	return ""
 }
 
+func GetChunkID(doc *schema.Document) string {
+	if doc == nil {
+		return ""
+	}
+	if v, ok := doc.MetaData[chunkIDKey]; ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func GetDatasetID(doc *schema.Document) string {
+	if doc == nil {
+		return ""
+	}
+	if v, ok := doc.MetaData[datasetIDKey]; ok {
+		return v.(string)
+	}
+	return ""
+}
+
 func GetKeywords(doc *schema.Document) []string {
 	if doc == nil {
 		return nil
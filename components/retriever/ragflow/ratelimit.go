@@ -0,0 +1,183 @@
+package ragflow
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultMaxOverrideLimiters 每个 Retriever 最多缓存的 WithAPIKey 覆盖 Key 的限流器数量
+const defaultMaxOverrideLimiters = 256
+
+// RateLimitConfig 定义了客户端限流参数
+type RateLimitConfig struct {
+	// QPS 每秒允许发出的请求数, 0 表示不限制
+	QPS float64
+	// Burst 令牌桶容量, 默认为 QPS 向上取整且不小于 1
+	Burst int
+	// MaxConcurrency 同时进行中的最大请求数, 0 表示不限制
+	MaxConcurrency int
+}
+
+// RateLimiter 是令牌桶限流器与并发信号量的组合, 可以在多个 Retriever 之间共享
+type RateLimiter struct {
+	config RateLimitConfig
+
+	qps   float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	sem chan struct{}
+}
+
+// NewRateLimiter 根据配置创建限流器
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{config: config, qps: config.QPS}
+	if config.QPS > 0 {
+		l.burst = float64(config.Burst)
+		if l.burst <= 0 {
+			l.burst = math.Max(1, math.Ceil(config.QPS))
+		}
+		l.tokens = l.burst
+		l.last = time.Now()
+	}
+	if config.MaxConcurrency > 0 {
+		l.sem = make(chan struct{}, config.MaxConcurrency)
+	}
+	return l
+}
+
+// limiterKey 以 API Key 的 SHA-256 摘要作为限流器的索引, 避免在内存中长期保留明文 Key
+func limiterKey(apiKey string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(apiKey))
+}
+
+var sharedRateLimiters sync.Map
+
+// SharedRateLimiter 返回 apiKey 对应的共享限流器, 同一个 apiKey 只会按首次传入的配置创建一次,
+// 之后传入不同的配置不会生效, 只会打印一条警告
+func SharedRateLimiter(apiKey string, config RateLimitConfig) *RateLimiter {
+	key := limiterKey(apiKey)
+	l, ok := sharedRateLimiters.Load(key)
+	if !ok {
+		l, ok = sharedRateLimiters.LoadOrStore(key, NewRateLimiter(config))
+	}
+	limiter := l.(*RateLimiter)
+	if ok && limiter.config != config {
+		log.Printf("[Warn]ragflow rate limiter for this api key already exists with config %+v, ignore config %+v", limiter.config, config)
+	}
+	return limiter
+}
+
+type overrideLimiterEntry struct {
+	key     [sha256.Size]byte
+	limiter *RateLimiter
+}
+
+// overrideLimiters 为 WithAPIKey 覆盖的 Key 各自维护一个限流器, 以 Key 的摘要为索引, 超出容量时淘汰最久未使用的
+type overrideLimiters struct {
+	config RateLimitConfig
+	max    int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+}
+
+func newOverrideLimiters(config RateLimitConfig, max int) *overrideLimiters {
+	return &overrideLimiters{
+		config:  config,
+		max:     max,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *overrideLimiters) get(apiKey string) *RateLimiter {
+	key := limiterKey(apiKey)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*overrideLimiterEntry).limiter
+	}
+	entry := &overrideLimiterEntry{key: key, limiter: NewRateLimiter(c.config)}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*overrideLimiterEntry).key)
+	}
+	return entry.limiter
+}
+
+// rateLimiter 返回本次请求使用的限流器: 通过 WithAPIKey 覆盖 Key 且未显式指定 RateLimiter 时, 使用该 Key 的限流器
+func (r *Retriever) rateLimiter(ctx context.Context) *RateLimiter {
+	if r.overrideLimiters != nil {
+		if key, ok := ctx.Value(apiKeyOverrideKey{}).(string); ok {
+			return r.overrideLimiters.get(key)
+		}
+	}
+	return r.limiter
+}
+
+// Acquire 等待令牌与并发名额, 返回释放并发名额的函数和等待耗时; ctx 结束时放弃等待并返回 ctx.Err()
+func (l *RateLimiter) Acquire(ctx context.Context) (release func(), wait time.Duration, err error) {
+	if l == nil {
+		return func() {}, 0, nil
+	}
+	start := time.Now()
+	if err = l.waitToken(ctx); err != nil {
+		return nil, time.Since(start), err
+	}
+	if l.sem == nil {
+		return func() {}, time.Since(start), nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.sem }) }, time.Since(start), nil
+}
+
+func (l *RateLimiter) waitToken(ctx context.Context) error {
+	if l.qps <= 0 {
+		return nil
+	}
+	// 先预留令牌, 令牌不足时计算需要等待的时间
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.qps)
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.qps * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 放弃等待时归还预留的令牌
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
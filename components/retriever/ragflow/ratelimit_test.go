@@ -0,0 +1,140 @@
+package ragflow
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimiter(t *testing.T) {
+	PatchConvey("test RateLimiter", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test token bucket", func() {
+			l := NewRateLimiter(RateLimitConfig{QPS: 20, Burst: 1})
+			release, wait, err := l.Acquire(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(wait, convey.ShouldBeLessThan, 10*time.Millisecond)
+			release()
+
+			_, wait, err = l.Acquire(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(wait, convey.ShouldBeGreaterThan, 30*time.Millisecond)
+		})
+
+		PatchConvey("test ctx canceled while waiting", func() {
+			l := NewRateLimiter(RateLimitConfig{QPS: 1, Burst: 1})
+			_, _, err := l.Acquire(ctx)
+			convey.So(err, convey.ShouldBeNil)
+
+			cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			_, _, err = l.Acquire(cctx)
+			convey.So(err, convey.ShouldEqual, context.DeadlineExceeded)
+		})
+
+		PatchConvey("test max concurrency", func() {
+			l := NewRateLimiter(RateLimitConfig{MaxConcurrency: 1})
+			release, _, err := l.Acquire(ctx)
+			convey.So(err, convey.ShouldBeNil)
+
+			cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			_, _, err = l.Acquire(cctx)
+			convey.So(err, convey.ShouldNotBeNil)
+
+			release()
+			release2, _, err := l.Acquire(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			release2()
+		})
+
+		PatchConvey("test shared by api key", func() {
+			a := SharedRateLimiter("key-shared-test", RateLimitConfig{QPS: 1})
+			b := SharedRateLimiter("key-shared-test", RateLimitConfig{QPS: 100})
+			c := SharedRateLimiter("key-other-test", RateLimitConfig{QPS: 1})
+			convey.So(a, convey.ShouldEqual, b)
+			convey.So(a, convey.ShouldNotEqual, c)
+		})
+
+		PatchConvey("test shared config mismatch warns", func() {
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+			a := SharedRateLimiter("key-mismatch-test", RateLimitConfig{QPS: 1})
+			convey.So(SharedRateLimiter("key-mismatch-test", RateLimitConfig{QPS: 1}), convey.ShouldEqual, a)
+			convey.So(buf.String(), convey.ShouldBeEmpty)
+			convey.So(SharedRateLimiter("key-mismatch-test", RateLimitConfig{QPS: 100}), convey.ShouldEqual, a)
+			convey.So(buf.String(), convey.ShouldContainSubstring, "[Warn]ragflow rate limiter")
+			convey.So(buf.String(), convey.ShouldNotContainSubstring, "key-mismatch-test")
+		})
+
+		PatchConvey("test limiter selection", func() {
+			provider := StaticCredential("provider-key")
+			a, err := NewRetriever(ctx, &RetrieverConfig{CredentialProvider: provider, DatasetIDs: []string{"kb1"}, RateLimit: &RateLimitConfig{QPS: 1}})
+			convey.So(err, convey.ShouldBeNil)
+			b, err := NewRetriever(ctx, &RetrieverConfig{CredentialProvider: provider, DatasetIDs: []string{"kb1"}, RateLimit: &RateLimitConfig{QPS: 1}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(a.limiter, convey.ShouldNotBeNil)
+			convey.So(a.limiter, convey.ShouldNotEqual, b.limiter)
+
+			c, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "key-selection-test", DatasetIDs: []string{"kb1"}, RateLimit: &RateLimitConfig{QPS: 1}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.limiter, convey.ShouldEqual, SharedRateLimiter("key-selection-test", RateLimitConfig{}))
+			convey.So(c.rateLimiter(ctx), convey.ShouldEqual, c.limiter)
+
+			// WithAPIKey 覆盖的调用使用该 Key 的限流器
+			tenantCtx := withAPIKeyOverride(ctx, "key-selection-tenant")
+			tenant := c.rateLimiter(tenantCtx)
+			convey.So(tenant, convey.ShouldNotBeNil)
+			convey.So(c.rateLimiter(tenantCtx), convey.ShouldEqual, tenant)
+			convey.So(tenant, convey.ShouldNotEqual, c.limiter)
+			convey.So(c.rateLimiter(withAPIKeyOverride(ctx, "key-selection-other")), convey.ShouldNotEqual, tenant)
+
+			// 显式指定的 RateLimiter 始终生效
+			explicit := NewRateLimiter(RateLimitConfig{QPS: 1})
+			d, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "key-selection-test", DatasetIDs: []string{"kb1"}, RateLimiter: explicit, RateLimit: &RateLimitConfig{QPS: 1}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(d.rateLimiter(tenantCtx), convey.ShouldEqual, explicit)
+		})
+
+		PatchConvey("test override limiters are bounded", func() {
+			c := newOverrideLimiters(RateLimitConfig{QPS: 1}, 2)
+			a := c.get("tenant-a")
+			b := c.get("tenant-b")
+			convey.So(c.get("tenant-a"), convey.ShouldEqual, a)
+			c.get("tenant-c")
+			convey.So(len(c.entries), convey.ShouldEqual, 2)
+			convey.So(c.get("tenant-a"), convey.ShouldEqual, a)
+			convey.So(c.get("tenant-b"), convey.ShouldNotEqual, b)
+			_, ok := c.entries[limiterKey("tenant-a")]
+			convey.So(ok, convey.ShouldBeTrue)
+		})
+
+		PatchConvey("test wait time in callback extra", func() {
+			server := newRetrievalServer(staticResponse(Chunk{ID: "1", Content: "c", Similarity: 0.9}))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:      "test",
+				Endpoint:    server.URL,
+				DatasetIDs:  []string{"kb1"},
+				RateLimiter: NewRateLimiter(RateLimitConfig{QPS: 5, Burst: 1}),
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			cbCtx, rec := newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rec.output.Extra[ExtraKeyRateLimitWait], convey.ShouldHaveSameTypeAs, time.Duration(0))
+			convey.So(rec.output.Extra[ExtraKeyRateLimitWait].(time.Duration), convey.ShouldBeGreaterThan, 50*time.Millisecond)
+		})
+	})
+}
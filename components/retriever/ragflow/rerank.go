@@ -0,0 +1,338 @@
+package ragflow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	originalScoreKey = "original_score"
+	rerankScoreKey   = "rerank_score"
+
+	// defaultPageSize 是 RAGFlow 检索接口 page_size 的默认值
+	defaultPageSize = 30
+
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+)
+
+// Reranker 在客户端根据查询为检索结果重新打分, 返回与 docs 一一对应的得分, 得分越高越相关
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []*schema.Document) ([]float64, error)
+}
+
+// RerankerFunc 将普通函数适配为 Reranker
+type RerankerFunc func(ctx context.Context, query string, docs []*schema.Document) ([]float64, error)
+
+func (f RerankerFunc) Rerank(ctx context.Context, query string, docs []*schema.Document) ([]float64, error) {
+	return f(ctx, query, docs)
+}
+
+// RerankConfig 定义了客户端重排, 不依赖 RAGFlow 中配置的 RerankID
+type RerankConfig struct {
+	// Reranker 重排实现, 必填
+	Reranker Reranker
+	// OverFetch 过采样倍数: 向 RAGFlow 请求 返回数量*OverFetch 个 chunk, 重排后截取前 返回数量 个, 默认 1.
+	// 返回数量取 WithPageSize, 未设置时取 RetrievalRequestOption.PageSize, 都未设置时为 RAGFlow 默认的 30;
+	// TopK 是 RAGFlow 参与向量计算的候选数, 不影响返回数量
+	OverFetch int
+}
+
+func (c *RerankConfig) overFetch() int {
+	if c == nil || c.OverFetch <= 1 {
+		return 1
+	}
+	return c.OverFetch
+}
+
+// resultLimit 返回本次检索期望的结果数量 (RAGFlow page_size): WithPageSize 优先, 其次为配置的 PageSize, 默认 30.
+// TopK 对应 RAGFlow 的 top_k, 是参与向量计算的候选数而不是返回数量, 这里不使用
+func (r *Retriever) resultLimit(implOpts *implOptions) int {
+	if implOpts.PageSize > 0 {
+		return implOpts.PageSize
+	}
+	if r.config.RetrievalRequestOption != nil && r.config.RetrievalRequestOption.PageSize != nil {
+		return *r.config.RetrievalRequestOption.PageSize
+	}
+	return defaultPageSize
+}
+
+// rerank 使用 Reranker 为 docs 重新打分并排序, 原始得分记录在 metadata 中, 结果截取前 limit 个
+func rerank(ctx context.Context, query string, docs []*schema.Document, c *RerankConfig, limit int) ([]*schema.Document, error) {
+	if c == nil || len(docs) == 0 {
+		return docs, nil
+	}
+	scores, err := c.Reranker.Rerank(ctx, query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("rerank failed: %w", err)
+	}
+	if len(scores) != len(docs) {
+		return nil, fmt.Errorf("rerank failed: got %d scores for %d docs", len(scores), len(docs))
+	}
+	for i, doc := range docs {
+		doc.MetaData[originalScoreKey] = doc.Score()
+		doc.MetaData[rerankScoreKey] = scores[i]
+		doc.WithScore(scores[i])
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Score() > docs[j].Score()
+	})
+	if limit > 0 && len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+// GetOriginalScore 返回文档重排前的得分
+func GetOriginalScore(doc *schema.Document) float64 {
+	if doc == nil {
+		return 0
+	}
+	if v, ok := doc.MetaData[originalScoreKey]; ok {
+		return v.(float64)
+	}
+	return 0
+}
+
+// GetRerankScore 返回 Reranker 给出的得分, 第二个返回值表示文档是否经过重排
+func GetRerankScore(doc *schema.Document) (float64, bool) {
+	if doc == nil {
+		return 0, false
+	}
+	if v, ok := doc.MetaData[rerankScoreKey]; ok {
+		return v.(float64), true
+	}
+	return 0, false
+}
+
+// BM25Reranker 是基于词法匹配的 BM25 重排, 以本次检索结果作为语料计算 IDF.
+// 英文等按单词切分并转为小写, 中日韩文本按相邻两个字符切分
+type BM25Reranker struct {
+	// K1 词频饱和参数, 默认 1.2
+	K1 float64
+	// B 文档长度归一化参数, 默认 0.75
+	B float64
+}
+
+func (b BM25Reranker) Rerank(ctx context.Context, query string, docs []*schema.Document) ([]float64, error) {
+	k1, bb := b.K1, b.B
+	if k1 <= 0 {
+		k1 = defaultBM25K1
+	}
+	if bb <= 0 {
+		bb = defaultBM25B
+	}
+
+	termFreqs := make([]map[string]int, len(docs))
+	docFreq := map[string]int{}
+	totalLen := 0
+	for i, doc := range docs {
+		terms := lexicalTerms(doc.Content)
+		totalLen += len(terms)
+		tf := make(map[string]int, len(terms))
+		for _, t := range terms {
+			tf[t]++
+		}
+		for t := range tf {
+			docFreq[t]++
+		}
+		termFreqs[i] = tf
+	}
+	avgLen := math.Max(1, float64(totalLen)/math.Max(1, float64(len(docs))))
+
+	queryTerms := map[string]bool{}
+	for _, t := range lexicalTerms(query) {
+		queryTerms[t] = true
+	}
+	n := float64(len(docs))
+	scores := make([]float64, len(docs))
+	for i, tf := range termFreqs {
+		docLen := 0
+		for _, c := range tf {
+			docLen += c
+		}
+		for t := range queryTerms {
+			f := float64(tf[t])
+			if f == 0 {
+				continue
+			}
+			df := float64(docFreq[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += idf * f * (k1 + 1) / (f + k1*(1-bb+bb*float64(docLen)/avgLen))
+		}
+	}
+	return scores, nil
+}
+
+// lexicalTerms 将文本切分为用于词法匹配的词项
+func lexicalTerms(text string) []string {
+	var terms []string
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			terms = append(terms, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				terms = append(terms, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+// HTTPReranker 适配通过 HTTP 提供的 cross-encoder 重排服务.
+// 默认请求体为 {"model","query","documents"}, 响应兼容 Cohere/Jina 风格的 {"results":[{"index","relevance_score"}]}
+// 与 TEI 风格的 [{"index","score"}]; TEI 请求可以将 BuildRequest 设置为 TEIRerankRequest, 其他协议可以通过 BuildRequest 与 ParseResponse 适配
+type HTTPReranker struct {
+	// URL 重排接口地址, 必填
+	URL string
+	// Model 模型名称, 可选
+	Model string
+	// APIKey 不为空时以 Bearer Token 的形式放入 Authorization 请求头
+	APIKey string
+	// Headers 额外的请求头
+	Headers map[string]string
+	// Client 发送请求的 HTTP Client, 默认为 http.DefaultClient
+	Client *http.Client
+	// BuildRequest 自定义请求体, 返回值会被序列化为 JSON
+	BuildRequest func(query string, documents []string) any
+	// ParseResponse 自定义响应解析, 返回与 documents 一一对应的得分
+	ParseResponse func(body []byte, n int) ([]float64, error)
+}
+
+type httpRerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+// TEIRerankRequest 构造 TEI (text-embeddings-inference) /rerank 接口的请求体 {"query","texts"}, 可用作 HTTPReranker.BuildRequest
+func TEIRerankRequest(query string, documents []string) any {
+	return map[string]any{"query": query, "texts": documents}
+}
+
+type httpRerankResult struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+func (h *HTTPReranker) Rerank(ctx context.Context, query string, docs []*schema.Document) ([]float64, error) {
+	documents := make([]string, len(docs))
+	for i, doc := range docs {
+		documents[i] = doc.Content
+	}
+	var payload any = &httpRerankRequest{Model: h.Model, Query: query, Documents: documents}
+	if h.BuildRequest != nil {
+		payload = h.BuildRequest(query, documents)
+	}
+	reqBody, err := sonic.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal rerank request failed: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("create rerank request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", getAuth(h.APIKey))
+	}
+	for name, value := range h.Headers {
+		req.Header.Set(name, value)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do rerank request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read rerank response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	if h.ParseResponse != nil {
+		return h.ParseResponse(body, len(docs))
+	}
+	return parseRerankResponse(body, len(docs))
+}
+
+func parseRerankResponse(body []byte, n int) ([]float64, error) {
+	var results []httpRerankResult
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		if err := sonic.Unmarshal(body, &results); err != nil {
+			return nil, fmt.Errorf("decode rerank response failed: %w", err)
+		}
+	} else {
+		wrapped := &struct {
+			Results []httpRerankResult `json:"results"`
+		}{}
+		if err := sonic.Unmarshal(body, wrapped); err != nil {
+			return nil, fmt.Errorf("decode rerank response failed: %w", err)
+		}
+		results = wrapped.Results
+	}
+
+	scores := make([]float64, n)
+	seen := make([]bool, n)
+	for _, res := range results {
+		if res.Index < 0 || res.Index >= n {
+			return nil, fmt.Errorf("rerank result index %d out of range", res.Index)
+		}
+		switch {
+		case res.RelevanceScore != nil:
+			scores[res.Index] = *res.RelevanceScore
+		case res.Score != nil:
+			scores[res.Index] = *res.Score
+		default:
+			return nil, fmt.Errorf("rerank result %d has no score", res.Index)
+		}
+		seen[res.Index] = true
+	}
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("rerank response has no score for document %d", i)
+		}
+	}
+	return scores, nil
+}
@@ -0,0 +1,126 @@
+package ragflow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRerank(t *testing.T) {
+	PatchConvey("test Rerank", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test bm25 reranker", func() {
+			docs := []*schema.Document{
+				{Content: "the weather is nice today"},
+				{Content: "how to reset your password: open settings and reset the password"},
+				{Content: "password policy"},
+				{Content: "重置密码需要在设置页面完成"},
+			}
+			scores, err := BM25Reranker{}.Rerank(ctx, "reset password", docs)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(scores[0], convey.ShouldEqual, 0)
+			convey.So(scores[1], convey.ShouldBeGreaterThan, scores[2])
+			convey.So(scores[2], convey.ShouldBeGreaterThan, 0)
+
+			scores, _ = BM25Reranker{}.Rerank(ctx, "如何重置密码", docs)
+			convey.So(scores[3], convey.ShouldBeGreaterThan, 0)
+			convey.So(scores[1], convey.ShouldEqual, 0)
+		})
+
+		PatchConvey("test http reranker", func() {
+			var got map[string]any
+			var auth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				auth = req.Header.Get("Authorization")
+				got = map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&got)
+				if req.URL.Path == "/tei" {
+					_, _ = w.Write([]byte(`[{"index":1,"score":0.9},{"index":0,"score":0.1}]`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"results":[{"index":1,"relevance_score":0.8},{"index":0,"relevance_score":0.3}]}`))
+			}))
+			defer server.Close()
+			docs := []*schema.Document{{Content: "a"}, {Content: "b"}}
+
+			scores, err := (&HTTPReranker{URL: server.URL + "/rerank", Model: "bge-reranker", APIKey: "k"}).Rerank(ctx, "q", docs)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(scores, convey.ShouldResemble, []float64{0.3, 0.8})
+			convey.So(got["model"], convey.ShouldEqual, "bge-reranker")
+			convey.So(got["documents"], convey.ShouldResemble, []any{"a", "b"})
+			convey.So(got, convey.ShouldNotContainKey, "texts")
+			convey.So(auth, convey.ShouldEqual, "Bearer k")
+
+			scores, err = (&HTTPReranker{URL: server.URL + "/tei", BuildRequest: TEIRerankRequest}).Rerank(ctx, "q", docs)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(scores, convey.ShouldResemble, []float64{0.1, 0.9})
+			convey.So(got["texts"], convey.ShouldResemble, []any{"a", "b"})
+			convey.So(got, convey.ShouldNotContainKey, "documents")
+
+			_, err = (&HTTPReranker{URL: server.URL}).Rerank(ctx, "q", append(docs, &schema.Document{Content: "c"}))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test retrieve with over fetch", func() {
+			server := newRetrievalServer(staticResponse(
+				Chunk{ID: "1", DocumentID: "1", Content: "unrelated text", Similarity: 0.9},
+				Chunk{ID: "2", DocumentID: "2", Content: "reset password steps", Similarity: 0.5},
+				Chunk{ID: "3", DocumentID: "3", Content: "password", Similarity: 0.7},
+			))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Rerank:     &RerankConfig{Reranker: BM25Reranker{}, OverFetch: 3},
+				RetrievalRequestOption: &RetrievalRequestOption{
+					TopK:     ptrOf(1024),
+					PageSize: ptrOf(2),
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "reset password")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"2", "3"})
+			convey.So(GetOriginalScore(docs[0]), convey.ShouldEqual, 0.5)
+			score, ok := GetRerankScore(docs[0])
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(docs[0].Score(), convey.ShouldEqual, score)
+
+			req := server.received()[0]
+			// 返回数量取 PageSize, TopK 只是候选数
+			convey.So(*req.PageSize, convey.ShouldEqual, 6)
+			convey.So(*req.TopK, convey.ShouldEqual, 1024)
+
+			// 候选数不能少于过采样后的 page_size
+			docs, err = r.Retrieve(ctx, "reset password", retriever.WithTopK(4))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+			convey.So(*server.received()[1].TopK, convey.ShouldEqual, 6)
+
+			// WithPageSize 覆盖配置的返回数量
+			docs, err = r.Retrieve(ctx, "reset password", WithPageSize(1))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"2"})
+			convey.So(*server.received()[2].PageSize, convey.ShouldEqual, 3)
+
+			_, err = r.Retrieve(ctx, "q", WithRerank(&RerankConfig{}))
+			convey.So(err, convey.ShouldNotBeNil)
+
+			docs, err = r.Retrieve(ctx, "reset password", WithRerank(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 3)
+			_, ok = GetRerankScore(docs[0])
+			convey.So(ok, convey.ShouldBeFalse)
+			convey.So(*server.received()[3].PageSize, convey.ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,174 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// searchTarget 是一次检索实际使用的数据集与文档范围
+type searchTarget struct {
+	DatasetIDs  []string
+	DocumentIDs []string
+}
+
+// resolvedNames 缓存 DatasetNames/DocumentNames 的解析结果
+type resolvedNames struct {
+	mu         sync.Mutex
+	target     *searchTarget
+	resolvedAt time.Time
+	// failedAt 最近一次刷新失败的时间, 在 NameRefreshInterval 内不再重试, 直接使用缓存
+	failedAt time.Time
+	// inflight 不为空时表示正在解析, 其他调用者等待它的结果而不是重复解析
+	inflight *nameResolution
+}
+
+// nameResolution 是一次进行中的名称解析, 完成后关闭 done
+type nameResolution struct {
+	done   chan struct{}
+	target *searchTarget
+	err    error
+}
+
+func (r *Retriever) hasNames() bool {
+	return len(r.config.DatasetNames) > 0 || len(r.config.DocumentNames) > 0
+}
+
+// getSearchTarget 返回本次检索的数据集与文档 ID, 名称解析结果过期时会重新解析, 刷新失败时沿用旧结果.
+// 解析在锁外进行且同一时间只有一个调用者解析; 已有缓存时其他调用者直接使用缓存, 刷新失败后在一个刷新间隔内不再重试
+func (r *Retriever) getSearchTarget(ctx context.Context) (*searchTarget, error) {
+	if !r.hasNames() {
+		return &searchTarget{DatasetIDs: r.config.DatasetIDs, DocumentIDs: r.config.DocumentIDs}, nil
+	}
+
+	interval := r.config.NameRefreshInterval
+	r.names.mu.Lock()
+	cached := r.names.target
+	if cached != nil && (interval <= 0 || time.Since(r.names.resolvedAt) < interval || time.Since(r.names.failedAt) < interval) {
+		r.names.mu.Unlock()
+		return cached, nil
+	}
+	if inflight := r.names.inflight; inflight != nil {
+		r.names.mu.Unlock()
+		if cached != nil {
+			return cached, nil
+		}
+		select {
+		case <-inflight.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// 正在解析的调用者被取消时, 由当前调用者重新解析
+		if inflight.err != nil && (errors.Is(inflight.err, context.Canceled) || errors.Is(inflight.err, context.DeadlineExceeded)) {
+			return r.getSearchTarget(ctx)
+		}
+		return inflight.target, inflight.err
+	}
+	inflight := &nameResolution{done: make(chan struct{})}
+	r.names.inflight = inflight
+	r.names.mu.Unlock()
+
+	target, err := r.resolveNames(ctx)
+
+	r.names.mu.Lock()
+	r.names.inflight = nil
+	switch {
+	case err == nil:
+		r.names.target, r.names.resolvedAt, r.names.failedAt = target, time.Now(), time.Time{}
+	case ctx.Err() == nil:
+		// 调用者取消导致的失败不计入退避
+		r.names.failedAt = time.Now()
+	}
+	r.names.mu.Unlock()
+	inflight.target, inflight.err = target, err
+	close(inflight.done)
+
+	if err != nil {
+		if cached != nil {
+			log.Printf("[Warn]refresh ragflow names failed, fallback to cached ids: %v", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	return target, nil
+}
+
+// ConfiguredDatasetIDs 返回检索使用的数据集 ID, 包括 DatasetNames 解析后的 ID; 为空表示未限定数据集 (例如只配置了 DocumentIDs)
+func (r *Retriever) ConfiguredDatasetIDs(ctx context.Context) ([]string, error) {
+	target, err := r.getSearchTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), target.DatasetIDs...), nil
+}
+
+// RefreshNames 立即重新解析 DatasetNames 与 DocumentNames, 名称不存在或不唯一时返回错误且保留旧结果
+func (r *Retriever) RefreshNames(ctx context.Context) error {
+	if !r.hasNames() {
+		return nil
+	}
+	target, err := r.resolveNames(ctx)
+	if err != nil {
+		return err
+	}
+	r.names.mu.Lock()
+	r.names.target, r.names.resolvedAt, r.names.failedAt = target, time.Now(), time.Time{}
+	r.names.mu.Unlock()
+	return nil
+}
+
+func (r *Retriever) resolveNames(ctx context.Context) (*searchTarget, error) {
+	datasetIDs := append([]string{}, r.config.DatasetIDs...)
+	for _, name := range r.config.DatasetNames {
+		datasets, err := r.ListDatasets(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("resolve dataset name %q failed: %w", name, err)
+		}
+		var ids []string
+		for _, d := range datasets {
+			if d.Name == name {
+				ids = append(ids, d.ID)
+			}
+		}
+		switch len(ids) {
+		case 0:
+			return nil, fmt.Errorf("dataset name %q not found", name)
+		case 1:
+			datasetIDs = append(datasetIDs, ids[0])
+		default:
+			return nil, fmt.Errorf("dataset name %q is ambiguous, matched ids: %s", name, strings.Join(ids, ","))
+		}
+	}
+
+	documentIDs := append([]string{}, r.config.DocumentIDs...)
+	if len(r.config.DocumentNames) > 0 && len(datasetIDs) == 0 {
+		return nil, fmt.Errorf("document_names requires dataset_ids or dataset_names")
+	}
+	for _, name := range r.config.DocumentNames {
+		var ids []string
+		for _, datasetID := range datasetIDs {
+			docs, err := r.ListDocuments(ctx, datasetID, name)
+			if err != nil {
+				return nil, fmt.Errorf("resolve document name %q in dataset %s failed: %w", name, datasetID, err)
+			}
+			for _, d := range docs {
+				if d.Name == name {
+					ids = append(ids, d.ID)
+				}
+			}
+		}
+		switch len(ids) {
+		case 0:
+			return nil, fmt.Errorf("document name %q not found", name)
+		case 1:
+			documentIDs = append(documentIDs, ids[0])
+		default:
+			return nil, fmt.Errorf("document name %q is ambiguous, matched ids: %s", name, strings.Join(ids, ","))
+		}
+	}
+	return &searchTarget{DatasetIDs: datasetIDs, DocumentIDs: documentIDs}, nil
+}
@@ -0,0 +1,171 @@
+package ragflow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newNameServer(datasets []Dataset, docs map[string][]DatasetDocument) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/api/v1/datasets":
+			res := &listDatasetsResponse{}
+			for _, d := range datasets {
+				if name := req.URL.Query().Get("name"); name == "" || name == d.Name {
+					res.Data = append(res.Data, d)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(res)
+		case strings.HasSuffix(req.URL.Path, "/documents"):
+			datasetID := strings.Split(req.URL.Path, "/")[4]
+			res := &listDocumentsResponse{}
+			for _, d := range docs[datasetID] {
+				if strings.Contains(d.Name, req.URL.Query().Get("keywords")) {
+					res.Data.Docs = append(res.Data.Docs, d)
+				}
+			}
+			res.Data.Total = int64(len(res.Data.Docs))
+			_ = json.NewEncoder(w).Encode(res)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestResolveNames(t *testing.T) {
+	PatchConvey("test resolve names", t, func() {
+		ctx := context.Background()
+		server := newNameServer([]Dataset{
+			{ID: "kb1", Name: "manual"},
+			{ID: "kb2", Name: "faq"},
+			{ID: "kb3", Name: "faq"},
+		}, map[string][]DatasetDocument{
+			"kb1": {{ID: "d1", Name: "install.pdf"}, {ID: "d2", Name: "install.pdf.bak"}},
+		})
+		defer server.Close()
+
+		PatchConvey("test resolve on create", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:        "test",
+				Endpoint:      server.URL,
+				DatasetNames:  []string{"manual"},
+				DocumentNames: []string{"install.pdf"},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			target, err := r.getSearchTarget(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(target.DatasetIDs, convey.ShouldResemble, []string{"kb1"})
+			convey.So(target.DocumentIDs, convey.ShouldResemble, []string{"d1"})
+		})
+
+		PatchConvey("test ambiguous name", func() {
+			_, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:       "test",
+				Endpoint:     server.URL,
+				DatasetNames: []string{"faq"},
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, `dataset name "faq" is ambiguous, matched ids: kb2,kb3`)
+		})
+
+		PatchConvey("test missing name", func() {
+			_, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:       "test",
+				Endpoint:     server.URL,
+				DatasetNames: []string{"unknown"},
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, `dataset name "unknown" not found`)
+		})
+
+		PatchConvey("test lazy resolve and refresh", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:              "test",
+				Endpoint:            server.URL,
+				DatasetIDs:          []string{"kb0"},
+				DatasetNames:        []string{"manual"},
+				LazyResolveNames:    true,
+				NameRefreshInterval: time.Hour,
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(r.names.target, convey.ShouldBeNil)
+
+			target, err := r.getSearchTarget(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(target.DatasetIDs, convey.ShouldResemble, []string{"kb0", "kb1"})
+
+			// 刷新失败时沿用缓存
+			r.names.resolvedAt = time.Now().Add(-2 * time.Hour)
+			r.config.DatasetNames = []string{"unknown"}
+			target, err = r.getSearchTarget(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(target.DatasetIDs, convey.ShouldResemble, []string{"kb0", "kb1"})
+			convey.So(r.RefreshNames(ctx), convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test refresh backoff and single flight", func() {
+			var hits int32
+			var failing atomic.Bool
+			counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				if failing.Load() {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				time.Sleep(20 * time.Millisecond)
+				server.Config.Handler.ServeHTTP(w, req)
+			}))
+			defer counting.Close()
+
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:              "test",
+				Endpoint:            counting.URL,
+				DatasetNames:        []string{"manual"},
+				LazyResolveNames:    true,
+				NameRefreshInterval: time.Hour,
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			// 并发的首次解析只请求一次
+			targets := make([]*searchTarget, 5)
+			errs := make([]error, 5)
+			var wg sync.WaitGroup
+			for i := range targets {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					targets[i], errs[i] = r.getSearchTarget(ctx)
+				}(i)
+			}
+			wg.Wait()
+			for i := range targets {
+				convey.So(errs[i], convey.ShouldBeNil)
+				convey.So(targets[i].DatasetIDs, convey.ShouldResemble, []string{"kb1"})
+			}
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, 1)
+
+			// 刷新失败后在刷新间隔内不再重试
+			failing.Store(true)
+			r.names.resolvedAt = time.Now().Add(-2 * time.Hour)
+			for i := 0; i < 3; i++ {
+				target, err := r.getSearchTarget(ctx)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(target.DatasetIDs, convey.ShouldResemble, []string{"kb1"})
+			}
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldBeGreaterThan, 1)
+			failedHits := atomic.LoadInt32(&hits)
+			_, _ = r.getSearchTarget(ctx)
+			convey.So(atomic.LoadInt32(&hits), convey.ShouldEqual, failedHits)
+		})
+	})
+}
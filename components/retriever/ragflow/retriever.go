@@ -2,11 +2,14 @@ package ragflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/cloudwego/eino/callbacks"
 	"github.com/cloudwego/eino/components"
 	"github.com/cloudwego/eino/components/retriever"
 	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"strings"
 	"time"
@@ -16,17 +19,84 @@ import (
 type RetrieverConfig struct {
 	// APIKey 是 RAGFlow API 的认证密钥
 	APIKey string
+	// CredentialProvider 在每次请求前提供 API Key, 优先于 APIKey, 认证失败时会刷新一次并重试
+	CredentialProvider CredentialProvider
 	// Endpoint RAGFlow API {address}, 默认为: https://ragflow.io
 	Endpoint string
 	// The IDs of the datasets to search. Defaults to None.
 	DatasetIDs []string
 	//The IDs of the documents to search. Defaults to None.
 	//You must ensure all selected documents use the same embedding model. Otherwise, an error will occur.
+	//Use Validate or ValidateOnCreate to detect this before the first query.
 	DocumentIDs []string
+	// DatasetNames 按名称指定要检索的数据集, 通过 RAGFlow 列表接口解析为 ID 后与 DatasetIDs 合并
+	DatasetNames []string
+	// DocumentNames 按名称指定要检索的文档, 在 DatasetIDs 与 DatasetNames 指定的数据集中解析
+	DocumentNames []string
+	// LazyResolveNames 为 true 时推迟到首次检索时解析名称, 否则在 NewRetriever 时解析, 名称不存在或不唯一时直接返回错误
+	LazyResolveNames bool
+	// NameRefreshInterval 名称解析结果的缓存时间, 过期后在下次检索时重新解析, 0 表示不刷新
+	NameRefreshInterval time.Duration
+	// RateLimit 客户端限流配置, 同一个 APIKey 的 Retriever 共享同一个限流器, WithAPIKey 覆盖的调用使用该 Key 的限流器 (每个 Retriever 最多保留最近使用的 256 个), 为空时不限流.
+	// 同一个 APIKey 的限流器按首个创建的 Retriever 的配置生效, 之后配置不同的 Retriever 仍共享该限流器并打印警告.
+	// 仅使用 CredentialProvider 时每个 Retriever 使用独立的限流器, 需要共享时请通过 RateLimiter 显式指定
+	RateLimit *RateLimitConfig
+	// RateLimiter 显式指定限流器, 优先于 RateLimit, 可用于在不同 APIKey 之间共享
+	RateLimiter *RateLimiter
+	// CircuitBreaker 检索请求的熔断配置, 为空时不熔断
+	CircuitBreaker *CircuitBreakerConfig
+	// Fallback 熔断器打开时用于兜底的检索器, 例如缓存快照或其他后端; 为空时直接返回 ErrCircuitOpen
+	Fallback retriever.Retriever
+	// Hedge 检索请求的对冲配置, 用于降低长尾延迟, 为空时不对冲
+	Hedge *HedgeConfig
+	// QueryTransformers 在发送检索请求前依次处理查询, 例如规范化空白、去除客套语、限制长度或改写
+	QueryTransformers []QueryTransformer
+	// Routing 按查询选择要检索的数据集, 为空时检索全部数据集
+	Routing *RoutingConfig
+	// TwoStage 两阶段检索配置, 先选出最相关的文档再在其中检索, 为空时只检索一次
+	TwoStage *TwoStage
+	// Feedback 基于 ImportantKeywords 的关键词反馈扩展, 为空时不扩展查询
+	Feedback *Feedback
+	// Score 文档得分的来源与归一化方式, ScoreThreshold 作用于计算后的得分, 为空时使用综合相似度
+	Score *ScoreConfig
+	// AdaptiveThreshold 自适应阈值配置, 结果不足时逐步放宽 ScoreThreshold, 为空时使用固定阈值
+	AdaptiveThreshold *AdaptiveThreshold
+	// Rerank 客户端重排配置, 在 RAGFlow 返回结果后、相邻 chunk 扩展前执行, 为空时不重排
+	Rerank *RerankConfig
+	// Diversification 在重排之后按 MMR 从过采样的候选中选出内容重复较少的结果, 并可限制每个源文档的 chunk 数, 为空时不处理
+	Diversification *Diversification
+	// PostProcessors 在构建文档后、计算 token 预算前依次处理检索结果, 例如去重、过滤、清理内容或重新排序
+	PostProcessors []PostProcessor
+	// Headers 每个请求都携带的静态请求头, 例如网关要求的租户标识; 不能包含 Authorization 与 Content-Type
+	Headers map[string]string
+	// HeaderFunc 根据 ctx 为每个请求生成额外的请求头, 优先于 Headers
+	HeaderFunc HeaderFunc
+	// TracerProvider 用于创建 OpenTelemetry span, 默认使用 otel.GetTracerProvider()
+	TracerProvider trace.TracerProvider
+	// Propagator 将 span 上下文写入请求头, 默认使用 W3C TraceContext
+	Propagator propagation.TextMapPropagator
+	// ValidateOnCreate 为 true 时在 NewRetriever 中执行 Validate, 发现任何问题都会返回错误
+	ValidateOnCreate bool
 	//知识库检索的额外配置
 	RetrievalRequestOption *RetrievalRequestOption
 	// Timeout 定义了 HTTP 连接超时时间 单位秒
 	Timeout time.Duration
+	// ContentFormat 返回文档 Content 的格式, 默认原样返回; 开启 Highlight 时命中词位置可通过 GetHighlights 获取
+	ContentFormat ContentFormat
+	// ImageMode 决定如何处理 Chunk 关联的图片, 默认仅记录 ImageID
+	ImageMode ImageMode
+	// ContextExpansion 为每个命中的 chunk 补充同一文档中前后相邻的 chunk, 为空时不扩展
+	ContextExpansion *ContextExpansion
+	// ChunkCache 相邻 chunk 扩展使用的文档 chunk 列表缓存, 为空时使用默认配置
+	ChunkCache *ChunkCacheConfig
+	// DocumentCollapse 将 chunk 按源文档折叠, 每个文档只返回一个结果, 为空时不折叠
+	DocumentCollapse *DocumentCollapse
+	// MaxTokens 返回文档的总 token 预算, 按得分从高到低选取放得下的文档, 0 表示不限制
+	MaxTokens int
+	// TokenCounter 计算 token 数的方式, 默认使用 HeuristicTokenCounter
+	TokenCounter TokenCounter
+	// TruncateToFit 为 true 时, 第一个放不下的文档会在句子边界截断后返回, 截掉的 token 数可通过 GetTruncatedTokens 获取
+	TruncateToFit bool
 }
 
 type Retriever struct {
@@ -34,6 +104,15 @@ type Retriever struct {
 	client        *http.Client
 	retrieverURL  string
 	authorization string
+
+	names            resolvedNames
+	limiter          *RateLimiter
+	overrideLimiters *overrideLimiters
+	breaker          *circuitBreaker
+	hedger           *hedger
+
+	credentials CredentialProvider
+	chunkCache  *chunkCache
 }
 
 func getURL(endPoint string) string {
@@ -48,10 +127,11 @@ func NewRetriever(ctx context.Context, config *RetrieverConfig) (*Retriever, err
 	if config == nil {
 		return nil, fmt.Errorf("config is required")
 	}
-	if config.APIKey == "" {
+	if config.APIKey == "" && config.CredentialProvider == nil {
 		return nil, fmt.Errorf("api_key is required")
 	}
-	if len(config.DatasetIDs) == 0 && len(config.DocumentIDs) == 0 {
+	if len(config.DatasetIDs) == 0 && len(config.DocumentIDs) == 0 &&
+		len(config.DatasetNames) == 0 && len(config.DocumentNames) == 0 {
 		return nil, fmt.Errorf("dataset_ids or document_ids,one of its is required")
 	}
 
@@ -62,12 +142,46 @@ func NewRetriever(ctx context.Context, config *RetrieverConfig) (*Retriever, err
 	if config.Timeout != 0 {
 		httpClient.Timeout = config.Timeout * time.Second
 	}
-	return &Retriever{
+	r := &Retriever{
 		config:        config,
 		client:        httpClient,
 		retrieverURL:  getURL(config.Endpoint),
 		authorization: getAuth(config.APIKey),
-	}, nil
+		limiter:       config.RateLimiter,
+		breaker:       newCircuitBreaker(config.CircuitBreaker),
+		hedger:        newHedger(config.Hedge),
+		credentials:   config.CredentialProvider,
+		chunkCache:    newChunkCache(config.ChunkCache),
+	}
+	if err := validateHeaders(config.Headers); err != nil {
+		return nil, err
+	}
+	if r.limiter == nil && config.RateLimit != nil {
+		if config.APIKey != "" {
+			r.limiter = SharedRateLimiter(config.APIKey, *config.RateLimit)
+		} else {
+			r.limiter = NewRateLimiter(*config.RateLimit)
+		}
+		r.overrideLimiters = newOverrideLimiters(*config.RateLimit, defaultMaxOverrideLimiters)
+	}
+	if err := r.getImplOptions().validate(); err != nil {
+		return nil, err
+	}
+	if !config.LazyResolveNames {
+		if err := r.RefreshNames(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if config.ValidateOnCreate {
+		report, err := r.Validate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err = report.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
 }
 
 // Retrieve 根据查询文本检索相关文档
@@ -81,39 +195,133 @@ func (r *Retriever) Retrieve(ctx context.Context, query string, opts ...retrieve
 	}
 
 	options := retriever.GetCommonOptions(baseOptions, opts...)
+	implOpts := r.getImplOptions(opts...)
 
 	ctx = callbacks.EnsureRunInfo(ctx, r.GetType(), components.ComponentOfRetriever)
+	// 改写查询
+	originalQuery := query
+	query, transformErr := transformQuery(ctx, query, implOpts.QueryTransformers)
 	// 开始检索回调
 	ctx = callbacks.OnStart(ctx, &retriever.CallbackInput{
-		Query:          query,
+		Query:          originalQuery,
 		TopK:           dereferenceOrZero(options.TopK),
 		ScoreThreshold: options.ScoreThreshold,
+		Extra: map[string]any{
+			ExtraKeyOriginalQuery: originalQuery,
+			ExtraKeyFinalQuery:    query,
+		},
 	})
+	ctx, stats := withCallStats(ctx)
+	ctx = withAPIKeyOverride(ctx, implOpts.APIKey)
+	ctx = withCallHeaders(ctx, implOpts.Headers)
+	ctx, span := r.tracer().Start(ctx, "ragflow.Retrieve", trace.WithAttributes(
+		attrQueryLength.Int(len(query)),
+		attrTopK.Int(dereferenceOrZero(options.TopK)),
+	))
+	if options.ScoreThreshold != nil {
+		span.SetAttributes(attrSimilarityThreshold.Float64(*options.ScoreThreshold))
+	}
 	// 设置回调和错误处理
 	defer func() {
+		span.SetAttributes(attrResultCount.Int(len(docs)))
+		endSpan(span, err)
 		if err != nil {
 			ctx = callbacks.OnError(ctx, err)
 		}
 	}()
 
+	if err = implOpts.validate(); err != nil {
+		return nil, err
+	}
+	if transformErr != nil {
+		return nil, transformErr
+	}
+
+	target, err := r.getSearchTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 显式指定数据集时不再路由
+	if len(implOpts.DatasetIDs) > 0 {
+		if target, err = restrictDatasets(target, implOpts.DatasetIDs); err != nil {
+			return nil, err
+		}
+	} else {
+		target = r.route(ctx, query, target, implOpts.Routing)
+	}
+	span.SetAttributes(attrDatasetIDs.StringSlice(target.DatasetIDs), attrDocumentIDs.StringSlice(target.DocumentIDs))
+
+	// 开启重排或多样化时按 OverFetch 多取一些候选
+	limit, pageSize := r.resultLimit(implOpts), implOpts.PageSize
+	if overFetch := max(implOpts.Rerank.overFetch(), implOpts.Diversification.overFetch()); overFetch > 1 {
+		pageSize = limit * overFetch
+	}
 	// 发送检索请求
-	result, err := r.doPost(ctx, query, options)
+	reqOptions := requestOptions(options, implOpts)
+	result, err := r.search(ctx, query, reqOptions, target, pageSize, implOpts)
+	if err == nil && implOpts.Feedback != nil {
+		// 融合结果保留的数量与单轮检索实际请求的 page_size 一致: 过采样时为 pageSize, 否则为 limit
+		roundSize := pageSize
+		if roundSize <= 0 {
+			roundSize = limit
+		}
+		result = r.feedbackSearch(ctx, query, reqOptions, target, pageSize, roundSize, implOpts, result)
+	}
+	if errors.Is(err, ErrCircuitOpen) && r.config.Fallback != nil {
+		stats.setFallback()
+		if docs, err = r.config.Fallback.Retrieve(ctx, query, opts...); err != nil {
+			return nil, fmt.Errorf("fallback retriever failed: %w", err)
+		}
+		ctx = callbacks.OnEnd(ctx, &retriever.CallbackOutput{Docs: docs, Extra: stats.extra()})
+		return docs, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
 	}
 	// 转换为统一的 Document 格式
 	docs = make([]*schema.Document, 0, len(result.Data.Chunks))
 
-	for _, record := range result.Data.Chunks {
-		if options.ScoreThreshold != nil && record.Similarity < *options.ScoreThreshold {
+	scores := scoreChunks(result.Data.Chunks, implOpts.Score)
+	threshold := options.ScoreThreshold
+	if implOpts.AdaptiveThreshold != nil {
+		threshold = ptrOf(implOpts.AdaptiveThreshold.adapt(scores, options.ScoreThreshold))
+		stats.setAppliedThreshold(*threshold)
+	}
+	for i, record := range result.Data.Chunks {
+		if threshold != nil && scores[i] < *threshold {
 			continue
 		}
-		doc := record.toDoc()
+		doc := record.toDoc(implOpts)
+		doc.WithScore(scores[i])
+		if implOpts.AdaptiveThreshold != nil {
+			setAppliedThreshold(doc, *threshold)
+		}
+		r.setImage(doc, record.ImageID, implOpts.ImageMode)
 		docs = append(docs, doc)
 	}
 
+	// 开启多样化时由 diversify 在全部候选中挑选并截取结果, 重排不截取
+	rerankLimit := limit
+	if implOpts.Diversification != nil {
+		rerankLimit = 0
+	}
+	if docs, err = rerank(ctx, query, docs, implOpts.Rerank, rerankLimit); err != nil {
+		return nil, err
+	}
+	if docs, err = diversify(ctx, docs, implOpts.Diversification, limit); err != nil {
+		return nil, err
+	}
+	if err = r.expandContext(ctx, docs, implOpts.ContextExpansion, implOpts.ContentFormat); err != nil {
+		return nil, err
+	}
+	docs = collapseDocuments(docs, result.Data.DocAggs, implOpts.DocumentCollapse)
+	if docs, err = postProcess(ctx, docs, implOpts.PostProcessors); err != nil {
+		return nil, err
+	}
+	docs = fitTokenBudget(docs, implOpts.MaxTokens, r.config.TokenCounter, implOpts.TruncateToFit)
+
 	// 结束检索回调
-	ctx = callbacks.OnEnd(ctx, &retriever.CallbackOutput{Docs: docs})
+	ctx = callbacks.OnEnd(ctx, &retriever.CallbackOutput{Docs: docs, Extra: stats.extra()})
 
 	return docs, nil
 }
@@ -0,0 +1,285 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// ExtraKeyRouting 本次检索的路由决策 (*RoutingInfo), 仅在开启路由时存在
+	ExtraKeyRouting = "routing"
+
+	defaultRoutingMinConfidence = 0.5
+)
+
+// RouteDecision 是路由器为一个查询选出的数据集
+type RouteDecision struct {
+	// DatasetIDs 需要检索的数据集
+	DatasetIDs []string `json:"dataset_ids"`
+	// Confidence 路由置信度, 取值 [0, 1]
+	Confidence float64 `json:"confidence"`
+	// Reason 路由依据, 便于排查
+	Reason string `json:"reason,omitempty"`
+}
+
+// Router 为每个查询选择要检索的数据集, candidates 为配置的全部数据集 ID
+type Router interface {
+	Route(ctx context.Context, query string, candidates []string) (*RouteDecision, error)
+}
+
+// RouterFunc 将普通函数适配为 Router
+type RouterFunc func(ctx context.Context, query string, candidates []string) (*RouteDecision, error)
+
+func (f RouterFunc) Route(ctx context.Context, query string, candidates []string) (*RouteDecision, error) {
+	return f(ctx, query, candidates)
+}
+
+// RoutingConfig 定义了按查询路由数据集的方式
+type RoutingConfig struct {
+	// Router 路由实现, 必填
+	Router Router
+	// MinConfidence 置信度低于该值、路由失败或没有选出数据集时检索全部数据集, 默认 0.5
+	MinConfidence float64
+}
+
+// RoutingInfo 记录一次路由决策, 通过 retriever.CallbackOutput.Extra 返回
+type RoutingInfo struct {
+	// Decision 路由器给出的决策, 路由失败时为空
+	Decision *RouteDecision `json:"decision,omitempty"`
+	// DatasetIDs 实际检索的数据集
+	DatasetIDs []string `json:"dataset_ids"`
+	// Fallback 是否回退到了全部数据集
+	Fallback bool `json:"fallback"`
+	// Error 路由失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+// route 根据路由决策缩小检索的数据集范围, 路由器给出的候选之外的数据集会被忽略
+func (r *Retriever) route(ctx context.Context, query string, target *searchTarget, c *RoutingConfig) *searchTarget {
+	if c == nil {
+		return target
+	}
+	minConfidence := c.MinConfidence
+	if minConfidence <= 0 {
+		minConfidence = defaultRoutingMinConfidence
+	}
+	info := &RoutingInfo{DatasetIDs: target.DatasetIDs, Fallback: true}
+	defer getCallStats(ctx).setRouting(info)
+
+	decision, err := c.Router.Route(ctx, query, target.DatasetIDs)
+	if err != nil {
+		log.Printf("[Warn]ragflow routing failed, fallback to all datasets: %v", err)
+		info.Error = err.Error()
+		return target
+	}
+	info.Decision = decision
+	if decision == nil || decision.Confidence < minConfidence {
+		return target
+	}
+	ids := filterCandidates(decision.DatasetIDs, target.DatasetIDs)
+	if len(ids) == 0 {
+		return target
+	}
+	info.DatasetIDs, info.Fallback = ids, false
+	return &searchTarget{DatasetIDs: ids, DocumentIDs: target.DocumentIDs}
+}
+
+// restrictDatasets 将检索范围限制为 ids 与已配置数据集的交集, 未配置任何数据集时直接使用 ids
+func restrictDatasets(target *searchTarget, ids []string) (*searchTarget, error) {
+	filtered := filterCandidates(ids, target.DatasetIDs)
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("none of dataset_ids %v is configured", ids)
+	}
+	return &searchTarget{DatasetIDs: filtered, DocumentIDs: target.DocumentIDs}, nil
+}
+
+// filterCandidates 去重并保留在 candidates 中的 ID, candidates 为空时不做限制
+func filterCandidates(ids, candidates []string) []string {
+	allowed := make(map[string]bool, len(candidates))
+	for _, id := range candidates {
+		allowed[id] = true
+	}
+	seen := map[string]bool{}
+	filtered := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] || len(candidates) > 0 && !allowed[id] {
+			continue
+		}
+		seen[id] = true
+		filtered = append(filtered, id)
+	}
+	return filtered
+}
+
+// RouteRule 是一条路由规则, 查询命中 Pattern 或包含任一 Keywords (忽略大小写) 时选中 DatasetIDs
+type RouteRule struct {
+	Pattern    *regexp.Regexp
+	Keywords   []string
+	DatasetIDs []string
+}
+
+func (rule *RouteRule) match(query, lowerQuery string) bool {
+	if rule.Pattern != nil && rule.Pattern.MatchString(query) {
+		return true
+	}
+	for _, kw := range rule.Keywords {
+		if kw != "" && strings.Contains(lowerQuery, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleRouter 按规则路由, 命中的所有规则的数据集取并集, 命中时置信度为 1, 否则为 0
+type RuleRouter struct {
+	Rules []RouteRule
+}
+
+func (rr *RuleRouter) Route(ctx context.Context, query string, candidates []string) (*RouteDecision, error) {
+	lowerQuery := strings.ToLower(query)
+	decision := &RouteDecision{}
+	var matched []string
+	for i := range rr.Rules {
+		if rr.Rules[i].match(query, lowerQuery) {
+			decision.DatasetIDs = append(decision.DatasetIDs, rr.Rules[i].DatasetIDs...)
+			matched = append(matched, fmt.Sprintf("rule %d", i))
+		}
+	}
+	if len(matched) > 0 {
+		decision.Confidence = 1
+		decision.Reason = "matched " + strings.Join(matched, ", ")
+	}
+	return decision, nil
+}
+
+// Language 是 LanguageRouter 识别的语言
+type Language string
+
+const (
+	LanguageChinese  Language = "zh"
+	LanguageJapanese Language = "ja"
+	LanguageKorean   Language = "ko"
+	LanguageRussian  Language = "ru"
+	LanguageArabic   Language = "ar"
+	// LanguageLatin 表示英文等使用拉丁字母的语言
+	LanguageLatin Language = "latin"
+)
+
+// DetectLanguage 按文字系统识别查询的主要语言, 置信度为该文字系统的字符占比; 没有文字时返回空字符串
+func DetectLanguage(text string) (Language, float64) {
+	counts := map[Language]int{}
+	total := 0
+	for _, r := range text {
+		var lang Language
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			lang = LanguageJapanese
+		case unicode.Is(unicode.Hangul, r):
+			lang = LanguageKorean
+		case unicode.Is(unicode.Han, r):
+			lang = LanguageChinese
+		case unicode.Is(unicode.Cyrillic, r):
+			lang = LanguageRussian
+		case unicode.Is(unicode.Arabic, r):
+			lang = LanguageArabic
+		case unicode.Is(unicode.Latin, r):
+			lang = LanguageLatin
+		default:
+			continue
+		}
+		counts[lang]++
+		total++
+	}
+	if total == 0 {
+		return "", 0
+	}
+	// 日文通常混用汉字, 出现假名即视为日文
+	if counts[LanguageJapanese] > 0 {
+		return LanguageJapanese, float64(counts[LanguageJapanese]+counts[LanguageChinese]) / float64(total)
+	}
+	langs := make([]Language, 0, len(counts))
+	for lang := range counts {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if counts[langs[i]] != counts[langs[j]] {
+			return counts[langs[i]] > counts[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	return langs[0], float64(counts[langs[0]]) / float64(total)
+}
+
+// LanguageRouter 按查询语言路由到对应的数据集
+type LanguageRouter struct {
+	Datasets map[Language][]string
+}
+
+func (lr *LanguageRouter) Route(ctx context.Context, query string, candidates []string) (*RouteDecision, error) {
+	lang, confidence := DetectLanguage(query)
+	ids, ok := lr.Datasets[lang]
+	if !ok {
+		return &RouteDecision{Reason: fmt.Sprintf("no datasets for language %q", lang)}, nil
+	}
+	return &RouteDecision{DatasetIDs: ids, Confidence: confidence, Reason: fmt.Sprintf("language %s", lang)}, nil
+}
+
+const chatModelRouterPrompt = `You route search queries to knowledge base datasets.
+Available datasets (id: description):
+%s
+Choose the datasets that are most likely to contain the answer to the user's query.
+Reply with JSON only, in the form {"dataset_ids":["id"],"confidence":0.0,"reason":"..."}, where confidence is between 0 and 1.`
+
+// ChatModelRouter 使用大模型根据数据集描述进行路由
+type ChatModelRouter struct {
+	// ChatModel 用于分类的模型, 可以传入 model.ChatModel 或 model.ToolCallingChatModel
+	ChatModel model.BaseChatModel
+	// Descriptions 数据集 ID 到描述的映射, 只有出现在这里且属于候选数据集的数据集会提供给模型
+	Descriptions map[string]string
+}
+
+func (cr *ChatModelRouter) Route(ctx context.Context, query string, candidates []string) (*RouteDecision, error) {
+	described := make([]string, 0, len(cr.Descriptions))
+	for id := range cr.Descriptions {
+		described = append(described, id)
+	}
+	ids := filterCandidates(described, candidates)
+	if len(ids) == 0 {
+		return &RouteDecision{Reason: "no described datasets among candidates"}, nil
+	}
+	sort.Strings(ids)
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("- %s: %s", id, cr.Descriptions[id]))
+	}
+	msg, err := cr.ChatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(chatModelRouterPrompt, strings.Join(lines, "\n"))),
+		schema.UserMessage(query),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("route query failed: %w", err)
+	}
+	decision := &RouteDecision{}
+	if err = sonic.UnmarshalString(extractJSON(msg.Content), decision); err != nil {
+		return nil, fmt.Errorf("decode route decision failed: %w", err)
+	}
+	return decision, nil
+}
+
+// extractJSON 提取模型回复中的 JSON 对象, 兼容 markdown 代码块
+func extractJSON(content string) string {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
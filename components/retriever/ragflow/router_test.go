@@ -0,0 +1,115 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouter(t *testing.T) {
+	PatchConvey("test Router", t, func() {
+		ctx := context.Background()
+		candidates := []string{"hr", "it", "zh", "en"}
+
+		PatchConvey("test rule router", func() {
+			router := &RuleRouter{Rules: []RouteRule{
+				{Keywords: []string{"VPN", "password"}, DatasetIDs: []string{"it"}},
+				{Pattern: regexp.MustCompile(`(?i)\b(leave|salary)\b`), DatasetIDs: []string{"hr"}},
+			}}
+			decision, err := router.Route(ctx, "reset vpn password and check salary", candidates)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(decision.DatasetIDs, convey.ShouldResemble, []string{"it", "hr"})
+			convey.So(decision.Confidence, convey.ShouldEqual, 1)
+
+			decision, _ = router.Route(ctx, "weather", candidates)
+			convey.So(decision.Confidence, convey.ShouldEqual, 0)
+		})
+
+		PatchConvey("test language router", func() {
+			lang, confidence := DetectLanguage("如何重置 VPN 密码")
+			convey.So(lang, convey.ShouldEqual, LanguageChinese)
+			convey.So(confidence, convey.ShouldAlmostEqual, 6.0/9.0)
+			lang, _ = DetectLanguage("パスワードを変更する")
+			convey.So(lang, convey.ShouldEqual, LanguageJapanese)
+			lang, confidence = DetectLanguage("reset password")
+			convey.So(lang, convey.ShouldEqual, LanguageLatin)
+			convey.So(confidence, convey.ShouldEqual, 1)
+
+			router := &LanguageRouter{Datasets: map[Language][]string{LanguageChinese: {"zh"}, LanguageLatin: {"en"}}}
+			decision, _ := router.Route(ctx, "重置密码", candidates)
+			convey.So(decision.DatasetIDs, convey.ShouldResemble, []string{"zh"})
+			decision, _ = router.Route(ctx, "Пароль", candidates)
+			convey.So(decision.DatasetIDs, convey.ShouldBeEmpty)
+		})
+
+		PatchConvey("test chat model router", func() {
+			cm := &rewriteModel{content: "```json\n{\"dataset_ids\":[\"it\"],\"confidence\":0.8,\"reason\":\"vpn\"}\n```"}
+			router := &ChatModelRouter{ChatModel: cm, Descriptions: map[string]string{"it": "IT support", "hr": "HR policies"}}
+			decision, err := router.Route(ctx, "vpn broken", candidates)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(decision.DatasetIDs, convey.ShouldResemble, []string{"it"})
+			convey.So(decision.Confidence, convey.ShouldEqual, 0.8)
+			convey.So(cm.input[0].Content, convey.ShouldContainSubstring, "- hr: HR policies\n- it: IT support")
+
+			// 只向模型提供候选数据集
+			cm.input = nil
+			_, err = router.Route(ctx, "vpn broken", []string{"it", "zh"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(cm.input[0].Content, convey.ShouldContainSubstring, "- it: IT support")
+			convey.So(cm.input[0].Content, convey.ShouldNotContainSubstring, "hr")
+
+			cm.input = nil
+			decision, err = router.Route(ctx, "vpn broken", []string{"zh"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(decision.DatasetIDs, convey.ShouldBeEmpty)
+			convey.So(cm.input, convey.ShouldBeNil)
+
+			cm.content = "not json"
+			_, err = router.Route(ctx, "vpn broken", candidates)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		PatchConvey("test retrieve with routing", func() {
+			server := newRetrievalServer(staticResponse(Chunk{ID: "1", DocumentID: "1", Similarity: 0.9}))
+			defer server.Close()
+			decision := &RouteDecision{DatasetIDs: []string{"it", "unknown"}, Confidence: 0.9}
+			var routeErr error
+			router := RouterFunc(func(ctx context.Context, query string, c []string) (*RouteDecision, error) {
+				return decision, routeErr
+			})
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: candidates,
+				Routing:    &RoutingConfig{Router: router, MinConfidence: 0.6},
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			cbCtx, rec := newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(server.received()[0].DatasetIDs, convey.ShouldResemble, []string{"it"})
+			info := rec.output.Extra[ExtraKeyRouting].(*RoutingInfo)
+			convey.So(info.Fallback, convey.ShouldBeFalse)
+			convey.So(info.Decision, convey.ShouldEqual, decision)
+
+			decision = &RouteDecision{DatasetIDs: []string{"it"}, Confidence: 0.3}
+			cbCtx, rec = newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(server.received()[1].DatasetIDs, convey.ShouldResemble, candidates)
+			convey.So(rec.output.Extra[ExtraKeyRouting].(*RoutingInfo).Fallback, convey.ShouldBeTrue)
+
+			routeErr = errors.New("boom")
+			cbCtx, rec = newCallbackContext(ctx)
+			_, err = r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(server.received()[2].DatasetIDs, convey.ShouldResemble, candidates)
+			convey.So(rec.output.Extra[ExtraKeyRouting].(*RoutingInfo).Error, convey.ShouldEqual, "boom")
+		})
+	})
+}
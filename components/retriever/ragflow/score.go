@@ -0,0 +1,194 @@
+package ragflow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	similarityKey       = "similarity"
+	vectorSimilarityKey = "vector_similarity"
+	termSimilarityKey   = "term_similarity"
+)
+
+// ScoreSource 决定文档得分取自 RAGFlow 返回的哪个相似度
+type ScoreSource string
+
+const (
+	// ScoreSourceSimilarity 使用 RAGFlow 的综合相似度, 默认值
+	ScoreSourceSimilarity ScoreSource = ""
+	// ScoreSourceVector 使用向量余弦相似度
+	ScoreSourceVector ScoreSource = "vector_similarity"
+	// ScoreSourceTerm 使用关键词相似度
+	ScoreSourceTerm ScoreSource = "term_similarity"
+	// ScoreSourceWeighted 使用 VectorWeight*向量相似度 + TermWeight*关键词相似度
+	ScoreSourceWeighted ScoreSource = "weighted"
+)
+
+func (s ScoreSource) valid() bool {
+	switch s {
+	case ScoreSourceSimilarity, ScoreSourceVector, ScoreSourceTerm, ScoreSourceWeighted:
+		return true
+	default:
+		return false
+	}
+}
+
+// ScoreNormalization 决定如何在一次检索结果内归一化得分
+type ScoreNormalization string
+
+const (
+	// ScoreNormalizationNone 不归一化, 默认值
+	ScoreNormalizationNone ScoreNormalization = ""
+	// ScoreNormalizationMinMax 线性映射到 [0, 1], 最高分为 1, 最低分为 0; 所有得分相同时均为 1
+	ScoreNormalizationMinMax ScoreNormalization = "min_max"
+	// ScoreNormalizationZScore 减去均值后除以标准差; 标准差为 0 时均为 0
+	ScoreNormalizationZScore ScoreNormalization = "z_score"
+	// ScoreNormalizationRank 按排名映射为 (n-rank)/n, 第一名为 1, 得分相同的文档排名相同
+	ScoreNormalizationRank ScoreNormalization = "rank"
+)
+
+func (n ScoreNormalization) valid() bool {
+	switch n {
+	case ScoreNormalizationNone, ScoreNormalizationMinMax, ScoreNormalizationZScore, ScoreNormalizationRank:
+		return true
+	default:
+		return false
+	}
+}
+
+// ScoreConfig 定义了文档得分的计算方式. ScoreThreshold 作用于计算后的得分,
+// 此时检索请求中的 similarity_threshold 会被置为 0, 由客户端统一过滤
+type ScoreConfig struct {
+	// Source 得分来源, 默认使用综合相似度
+	Source ScoreSource
+	// VectorWeight Source 为 ScoreSourceWeighted 时向量相似度的权重
+	VectorWeight float64
+	// TermWeight Source 为 ScoreSourceWeighted 时关键词相似度的权重
+	TermWeight float64
+	// Normalization 归一化方式, 默认不归一化
+	Normalization ScoreNormalization
+}
+
+func (c *ScoreConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if !c.Source.valid() {
+		return fmt.Errorf("unknown score source: %s", c.Source)
+	}
+	if !c.Normalization.valid() {
+		return fmt.Errorf("unknown score normalization: %s", c.Normalization)
+	}
+	if c.Source == ScoreSourceWeighted && c.VectorWeight == 0 && c.TermWeight == 0 {
+		return fmt.Errorf("vector_weight or term_weight is required for weighted score")
+	}
+	return nil
+}
+
+// custom 表示得分不再等同于 RAGFlow 的综合相似度
+func (c *ScoreConfig) custom() bool {
+	return c != nil && (c.Source != ScoreSourceSimilarity || c.Normalization != ScoreNormalizationNone)
+}
+
+// scoreChunks 按配置计算每个 chunk 的得分
+func scoreChunks(chunks []Chunk, c *ScoreConfig) []float64 {
+	scores := make([]float64, len(chunks))
+	for i, chunk := range chunks {
+		scores[i] = chunk.Similarity
+		if c == nil {
+			continue
+		}
+		switch c.Source {
+		case ScoreSourceVector:
+			scores[i] = chunk.VectorSimilarity
+		case ScoreSourceTerm:
+			scores[i] = chunk.TermSimilarity
+		case ScoreSourceWeighted:
+			scores[i] = c.VectorWeight*chunk.VectorSimilarity + c.TermWeight*chunk.TermSimilarity
+		}
+	}
+	if c != nil {
+		normalizeScores(scores, c.Normalization)
+	}
+	return scores
+}
+
+func normalizeScores(scores []float64, n ScoreNormalization) {
+	if len(scores) == 0 {
+		return
+	}
+	switch n {
+	case ScoreNormalizationMinMax:
+		lo, hi := scores[0], scores[0]
+		for _, s := range scores {
+			lo, hi = math.Min(lo, s), math.Max(hi, s)
+		}
+		for i := range scores {
+			if hi > lo {
+				scores[i] = (scores[i] - lo) / (hi - lo)
+			} else {
+				scores[i] = 1
+			}
+		}
+	case ScoreNormalizationZScore:
+		mean := 0.0
+		for _, s := range scores {
+			mean += s
+		}
+		mean /= float64(len(scores))
+		variance := 0.0
+		for _, s := range scores {
+			variance += (s - mean) * (s - mean)
+		}
+		std := math.Sqrt(variance / float64(len(scores)))
+		for i := range scores {
+			if std > 0 {
+				scores[i] = (scores[i] - mean) / std
+			} else {
+				scores[i] = 0
+			}
+		}
+	case ScoreNormalizationRank:
+		order := make([]int, len(scores))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return scores[order[i]] > scores[order[j]]
+		})
+		total := float64(len(scores))
+		ranked := make([]float64, len(scores))
+		rank := 0
+		for pos, idx := range order {
+			if pos > 0 && scores[idx] != scores[order[pos-1]] {
+				rank = pos
+			}
+			ranked[idx] = (total - float64(rank)) / total
+		}
+		copy(scores, ranked)
+	}
+}
+
+func setSimilarities(doc *schema.Document, chunk *Chunk) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[similarityKey] = chunk.Similarity
+	doc.MetaData[vectorSimilarityKey] = chunk.VectorSimilarity
+	doc.MetaData[termSimilarityKey] = chunk.TermSimilarity
+}
+
+// GetSimilarities 返回 RAGFlow 给出的原始综合相似度、向量相似度与关键词相似度
+func GetSimilarities(doc *schema.Document) (similarity, vector, term float64) {
+	if doc == nil {
+		return 0, 0, 0
+	}
+	similarity, _ = doc.MetaData[similarityKey].(float64)
+	vector, _ = doc.MetaData[vectorSimilarityKey].(float64)
+	term, _ = doc.MetaData[termSimilarityKey].(float64)
+	return similarity, vector, term
+}
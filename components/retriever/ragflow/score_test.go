@@ -0,0 +1,76 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestScore(t *testing.T) {
+	PatchConvey("test Score", t, func() {
+		ctx := context.Background()
+		chunks := []Chunk{
+			{ID: "1", DocumentID: "1", Similarity: 0.30, VectorSimilarity: 0.9, TermSimilarity: 0.1},
+			{ID: "2", DocumentID: "2", Similarity: 0.50, VectorSimilarity: 0.5, TermSimilarity: 0.5},
+			{ID: "3", DocumentID: "3", Similarity: 0.40, VectorSimilarity: 0.1, TermSimilarity: 0.9},
+			{ID: "4", DocumentID: "4", Similarity: 0.40, VectorSimilarity: 0.1, TermSimilarity: 0.3},
+		}
+
+		PatchConvey("test sources", func() {
+			convey.So(scoreChunks(chunks, nil), convey.ShouldResemble, []float64{0.3, 0.5, 0.4, 0.4})
+			convey.So(scoreChunks(chunks, &ScoreConfig{Source: ScoreSourceVector}), convey.ShouldResemble, []float64{0.9, 0.5, 0.1, 0.1})
+			convey.So(scoreChunks(chunks, &ScoreConfig{Source: ScoreSourceTerm}), convey.ShouldResemble, []float64{0.1, 0.5, 0.9, 0.3})
+			scores := scoreChunks(chunks, &ScoreConfig{Source: ScoreSourceWeighted, VectorWeight: 0.5, TermWeight: 0.5})
+			convey.So(scores[0], convey.ShouldAlmostEqual, 0.5)
+			convey.So(scores[3], convey.ShouldAlmostEqual, 0.2)
+		})
+
+		PatchConvey("test normalization", func() {
+			scores := scoreChunks(chunks, &ScoreConfig{Normalization: ScoreNormalizationMinMax})
+			convey.So(scores[0], convey.ShouldAlmostEqual, 0)
+			convey.So(scores[1], convey.ShouldAlmostEqual, 1)
+			convey.So(scores[2], convey.ShouldAlmostEqual, 0.5)
+
+			scores = scoreChunks(chunks, &ScoreConfig{Normalization: ScoreNormalizationZScore})
+			convey.So(scores[0]+scores[1]+scores[2]+scores[3], convey.ShouldAlmostEqual, 0)
+			convey.So(scores[1], convey.ShouldAlmostEqual, 1.4142135, 1e-6)
+
+			convey.So(scoreChunks(chunks, &ScoreConfig{Normalization: ScoreNormalizationRank}), convey.ShouldResemble, []float64{0.25, 1, 0.75, 0.75})
+			convey.So(scoreChunks(chunks[:1], &ScoreConfig{Normalization: ScoreNormalizationMinMax}), convey.ShouldResemble, []float64{1})
+		})
+
+		PatchConvey("test retrieve with score config", func() {
+			server := newRetrievalServer(staticResponse(chunks...))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				Score:      &ScoreConfig{Source: ScoreSourceVector, Normalization: ScoreNormalizationMinMax},
+				RetrievalRequestOption: &RetrievalRequestOption{
+					SimilarityThreshold: ptrOf(0.45),
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1", "2"})
+			convey.So(docs[0].Score(), convey.ShouldEqual, 1)
+			similarity, vector, term := GetSimilarities(docs[0])
+			convey.So([]float64{similarity, vector, term}, convey.ShouldResemble, []float64{0.3, 0.9, 0.1})
+			convey.So(*server.received()[0].SimilarityThreshold, convey.ShouldEqual, 0)
+
+			docs, err = r.Retrieve(ctx, "q", WithScoreConfig(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"2"})
+			convey.So(*server.received()[1].SimilarityThreshold, convey.ShouldEqual, 0.45)
+
+			_, err = r.Retrieve(ctx, "q", WithScoreConfig(&ScoreConfig{Source: ScoreSourceWeighted}))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = r.Retrieve(ctx, "q", WithScoreConfig(&ScoreConfig{Normalization: "log"}))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package ragflow
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	appliedThresholdKey = "applied_threshold"
+
+	// defaultSimilarityThreshold 是 RAGFlow 检索接口 similarity_threshold 的默认值
+	defaultSimilarityThreshold = 0.2
+	defaultThresholdStep       = 0.05
+)
+
+// AdaptiveThreshold 定义了自适应阈值: 从 ScoreThreshold 开始按 Step 逐步放宽, 直到结果数不少于 MinResults 或降到 Floor.
+// 检索请求按 Floor 取回候选后在本地过滤, 不会重复请求 RAGFlow
+type AdaptiveThreshold struct {
+	// MinResults 期望的最少结果数, 必须大于 0
+	MinResults int
+	// Floor 阈值下限, 放宽后的阈值不会低于该值
+	Floor float64
+	// Step 每次放宽的幅度, 默认 0.05
+	Step float64
+}
+
+func (a *AdaptiveThreshold) validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.MinResults <= 0 {
+		return fmt.Errorf("min_results must be positive for adaptive threshold")
+	}
+	if a.Step < 0 {
+		return fmt.Errorf("step must not be negative for adaptive threshold")
+	}
+	return nil
+}
+
+// adapt 返回满足 MinResults 的最高阈值, 不低于 Floor; start 为 nil 时从 RAGFlow 的默认阈值开始
+func (a *AdaptiveThreshold) adapt(scores []float64, start *float64) float64 {
+	threshold := defaultSimilarityThreshold
+	if start != nil {
+		threshold = *start
+	}
+	step := a.Step
+	if step <= 0 {
+		step = defaultThresholdStep
+	}
+	// 候选按 Floor 取回, 起始阈值低于 Floor 时以 Floor 为准
+	threshold = max(threshold, a.Floor)
+	current := threshold
+	for k := 1; current > a.Floor && countAtLeast(scores, current) < a.MinResults; k++ {
+		// 按起始值重新计算并取整, 避免浮点误差累积
+		current = max(a.Floor, math.Round((threshold-float64(k)*step)*1e9)/1e9)
+	}
+	return current
+}
+
+func countAtLeast(scores []float64, threshold float64) int {
+	n := 0
+	for _, s := range scores {
+		if s >= threshold {
+			n++
+		}
+	}
+	return n
+}
+
+// requestOptions 返回实际发送给 RAGFlow 的检索选项. 自定义得分或自适应阈值时由客户端过滤,
+// 此时请求中的阈值会放宽, 避免 RAGFlow 按综合相似度提前过滤
+func requestOptions(options *retriever.Options, implOpts *implOptions) *retriever.Options {
+	var threshold *float64
+	switch {
+	case implOpts.Score.custom() && (options.ScoreThreshold != nil || implOpts.AdaptiveThreshold != nil):
+		threshold = ptrOf(0.0)
+	case implOpts.AdaptiveThreshold != nil:
+		threshold = ptrOf(implOpts.AdaptiveThreshold.Floor)
+	default:
+		return options
+	}
+	copied := *options
+	copied.ScoreThreshold = threshold
+	return &copied
+}
+
+func setAppliedThreshold(doc *schema.Document, threshold float64) {
+	if doc == nil {
+		return
+	}
+	doc.MetaData[appliedThresholdKey] = threshold
+}
+
+// GetAppliedThreshold 返回自适应阈值模式下本次检索实际使用的阈值, 第二个返回值表示是否开启了自适应阈值
+func GetAppliedThreshold(doc *schema.Document) (float64, bool) {
+	if doc == nil {
+		return 0, false
+	}
+	v, ok := doc.MetaData[appliedThresholdKey].(float64)
+	return v, ok
+}
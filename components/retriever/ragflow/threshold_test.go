@@ -0,0 +1,62 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdaptiveThreshold(t *testing.T) {
+	PatchConvey("test AdaptiveThreshold", t, func() {
+		ctx := context.Background()
+
+		PatchConvey("test adapt", func() {
+			a := &AdaptiveThreshold{MinResults: 2, Floor: 0.3, Step: 0.1}
+			convey.So(a.adapt([]float64{0.9, 0.8, 0.1}, ptrOf(0.7)), convey.ShouldEqual, 0.7)
+			convey.So(a.adapt([]float64{0.65, 0.45, 0.1}, ptrOf(0.7)), convey.ShouldEqual, 0.4)
+			convey.So(a.adapt([]float64{0.65, 0.1}, ptrOf(0.7)), convey.ShouldEqual, 0.3)
+			convey.So(a.adapt([]float64{0.5}, nil), convey.ShouldEqual, 0.3)
+		})
+
+		PatchConvey("test retrieve with adaptive threshold", func() {
+			server := newRetrievalServer(staticResponse(
+				Chunk{ID: "1", DocumentID: "1", Similarity: 0.62},
+				Chunk{ID: "2", DocumentID: "2", Similarity: 0.41},
+				Chunk{ID: "3", DocumentID: "3", Similarity: 0.25},
+			))
+			defer server.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:            "key",
+				Endpoint:          server.URL,
+				DatasetIDs:        []string{"kb1"},
+				AdaptiveThreshold: &AdaptiveThreshold{MinResults: 2, Floor: 0.2},
+				RetrievalRequestOption: &RetrievalRequestOption{
+					SimilarityThreshold: ptrOf(0.6),
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			cbCtx, rec := newCallbackContext(ctx)
+			docs, err := r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1", "2"})
+			threshold, ok := GetAppliedThreshold(docs[0])
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(threshold, convey.ShouldEqual, 0.4)
+			convey.So(rec.output.Extra[ExtraKeyAppliedThreshold], convey.ShouldEqual, 0.4)
+			convey.So(*server.received()[0].SimilarityThreshold, convey.ShouldEqual, 0.2)
+
+			docs, err = r.Retrieve(ctx, "q", WithAdaptiveThreshold(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"1"})
+			_, ok = GetAppliedThreshold(docs[0])
+			convey.So(ok, convey.ShouldBeFalse)
+			convey.So(*server.received()[1].SimilarityThreshold, convey.ShouldEqual, 0.6)
+
+			_, err = r.Retrieve(ctx, "q", WithAdaptiveThreshold(&AdaptiveThreshold{}))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
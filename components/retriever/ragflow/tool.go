@@ -0,0 +1,149 @@
+package ragflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultToolName        = "search_knowledge_base"
+	defaultToolDescription = "Search the knowledge base for passages relevant to a question. " +
+		"Use it when the answer may depend on internal documents. " +
+		"Results are numbered passages; cite them as [n] in your answer."
+	defaultToolMaxTopK = 10
+)
+
+// ToolConfig 定义了将 Retriever 包装为工具时的配置
+type ToolConfig struct {
+	// Name 工具名称, 默认 search_knowledge_base
+	Name string
+	// Description 工具描述, 默认提示模型在需要内部文档时调用并以 [n] 引用结果
+	Description string
+	// Datasets 允许模型选择的数据集 ID 到描述的映射, 会写入 dataset_ids 参数的描述中; 为空时不提示可选数据集
+	Datasets map[string]string
+	// MaxTopK top_k 参数的上限, 同时是未指定 top_k 时的返回数量, 默认 10
+	MaxTopK int
+	// Formatter 自定义结果格式, 默认使用 FormatCitations
+	Formatter func(docs []*schema.Document) string
+}
+
+// ToolInput 是检索工具的参数
+type ToolInput struct {
+	Query      string   `json:"query" jsonschema:"required,description=The search query. Rewrite the user question into a concise and self-contained query."`
+	TopK       int      `json:"top_k,omitempty" jsonschema:"description=Maximum number of passages to return."`
+	DatasetIDs []string `json:"dataset_ids,omitempty" jsonschema:"description=Restrict the search to these dataset IDs. Omit to search all datasets."`
+}
+
+// NewTool 将 Retriever 包装为 tool.InvokableTool, 供 ReAct 等 Agent 自行决定何时检索知识库
+func NewTool(ctx context.Context, r *Retriever, config *ToolConfig) (tool.InvokableTool, error) {
+	if r == nil {
+		return nil, fmt.Errorf("retriever is required")
+	}
+	if config == nil {
+		config = &ToolConfig{}
+	}
+	name, desc := config.Name, config.Description
+	if name == "" {
+		name = defaultToolName
+	}
+	if desc == "" {
+		desc = defaultToolDescription
+	}
+	maxTopK := config.MaxTopK
+	if maxTopK <= 0 {
+		maxTopK = defaultToolMaxTopK
+	}
+	format := config.Formatter
+	if format == nil {
+		format = FormatCitations
+	}
+
+	info, err := utils.GoStruct2ToolInfo[ToolInput](name, desc)
+	if err != nil {
+		return nil, fmt.Errorf("generate tool schema failed: %w", err)
+	}
+	describeToolParams(info, config.Datasets, maxTopK)
+
+	// 参数错误和检索失败以文本形式返回给模型, 便于模型修正参数后重试, 而不是中断整个 Agent 的运行;
+	// 调用被取消或超时时直接返回 error, 由调用方结束本次运行
+	return utils.NewTool(info, func(ctx context.Context, input ToolInput) (string, error) {
+		if strings.TrimSpace(input.Query) == "" {
+			return "Error: query is required.", nil
+		}
+		// top_k 是返回的段落数, 对应 RAGFlow 的 page_size; RAGFlow 的 top_k 是向量计算的候选数, 保持配置不变
+		topK := input.TopK
+		if topK <= 0 || topK > maxTopK {
+			topK = maxTopK
+		}
+		opts := []retriever.Option{WithPageSize(topK)}
+		if len(input.DatasetIDs) > 0 {
+			opts = append(opts, WithDatasetIDs(input.DatasetIDs...))
+		}
+		docs, err := r.Retrieve(ctx, input.Query, opts...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Error: search failed: %v", err), nil
+		}
+		if len(docs) > topK {
+			docs = docs[:topK]
+		}
+		return format(docs), nil
+	}), nil
+}
+
+// describeToolParams 将 top_k 上限与可选数据集补充到参数描述中
+func describeToolParams(info *schema.ToolInfo, datasets map[string]string, maxTopK int) {
+	js, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil || js == nil {
+		return
+	}
+	if p, ok := js.Properties["top_k"]; ok && p.Value != nil {
+		p.Value.Description += fmt.Sprintf(" At most %d, defaults to %d.", maxTopK, maxTopK)
+	}
+	if p, ok := js.Properties["dataset_ids"]; ok && p.Value != nil && len(datasets) > 0 {
+		ids := make([]string, 0, len(datasets))
+		for id := range datasets {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		lines := make([]string, 0, len(ids))
+		for _, id := range ids {
+			lines = append(lines, fmt.Sprintf("%s (%s)", id, datasets[id]))
+		}
+		p.Value.Description += " Available datasets: " + strings.Join(lines, "; ") + "."
+	}
+	info.ParamsOneOf = schema.NewParamsOneOfByOpenAPIV3(js)
+}
+
+// FormatCitations 将文档格式化为带编号的段落, 每段附带来源文档与引用 ID (chunk ID), 便于模型以 [n] 引用
+func FormatCitations(docs []*schema.Document) string {
+	if len(docs) == 0 {
+		return "No relevant passages found."
+	}
+	var sb strings.Builder
+	for i, doc := range docs {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		citationID := GetChunkID(doc)
+		if citationID == "" {
+			citationID = doc.ID
+		}
+		fmt.Fprintf(&sb, "[%d] citation_id: %s", i+1, citationID)
+		if name := GetOrgDocName(doc); name != "" {
+			fmt.Fprintf(&sb, ", source: %s", name)
+		}
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimSpace(doc.Content))
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTool(t *testing.T) {
+	PatchConvey("test Tool", t, func() {
+		ctx := context.Background()
+		server := newRetrievalServer(staticResponse(
+			Chunk{ID: "c1", DocumentID: "d1", DocumentKeyWord: "vpn.md", Content: "Restart the VPN client.", Similarity: 0.9},
+			Chunk{ID: "c2", DocumentID: "d2", Content: " Check the password. ", Similarity: 0.8},
+			Chunk{ID: "c3", DocumentID: "d3", Content: "Call IT.", Similarity: 0.7},
+		))
+		defer server.Close()
+		r, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "key", Endpoint: server.URL, DatasetIDs: []string{"it", "hr"}})
+		convey.So(err, convey.ShouldBeNil)
+
+		PatchConvey("test schema", func() {
+			tl, err := NewTool(ctx, r, &ToolConfig{Name: "kb", Datasets: map[string]string{"it": "IT support"}, MaxTopK: 5})
+			convey.So(err, convey.ShouldBeNil)
+			info, err := tl.Info(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(info.Name, convey.ShouldEqual, "kb")
+			convey.So(info.Desc, convey.ShouldEqual, defaultToolDescription)
+			js, err := info.ParamsOneOf.ToOpenAPIV3()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(js.Required, convey.ShouldResemble, []string{"query"})
+			convey.So(js.Properties["top_k"].Value.Description, convey.ShouldContainSubstring, "At most 5")
+			convey.So(js.Properties["dataset_ids"].Value.Description, convey.ShouldContainSubstring, "it (IT support)")
+		})
+
+		PatchConvey("test invoke", func() {
+			tl, err := NewTool(ctx, r, &ToolConfig{MaxTopK: 5})
+			convey.So(err, convey.ShouldBeNil)
+			out, err := tl.InvokableRun(ctx, `{"query":"vpn down","top_k":2,"dataset_ids":["it"]}`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(out, convey.ShouldEqual, "[1] citation_id: c1, source: vpn.md\nRestart the VPN client.\n\n"+
+				"[2] citation_id: c2\nCheck the password.")
+			req := server.received()[0]
+			convey.So(req.DatasetIDs, convey.ShouldResemble, []string{"it"})
+			convey.So(*req.PageSize, convey.ShouldEqual, 2)
+			convey.So(req.TopK, convey.ShouldBeNil)
+
+			// 参数错误以文本形式返回给模型
+			out, err = tl.InvokableRun(ctx, `{"query":"vpn down","dataset_ids":["finance"]}`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(out, convey.ShouldContainSubstring, "none of dataset_ids [finance] is configured")
+			out, err = tl.InvokableRun(ctx, `{"query":" "}`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(out, convey.ShouldEqual, "Error: query is required.")
+
+			// 调用被取消时返回 error 而不是文本
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			out, err = tl.InvokableRun(cancelled, `{"query":"vpn down"}`)
+			convey.So(errors.Is(err, context.Canceled), convey.ShouldBeTrue)
+			convey.So(out, convey.ShouldBeEmpty)
+
+			convey.So(FormatCitations(nil), convey.ShouldEqual, "No relevant passages found.")
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Abei1uo/eino-ext/components/retriever/ragflow"
+
+// span 属性名
+const (
+	attrQueryLength         = attribute.Key("ragflow.query.length")
+	attrDatasetIDs          = attribute.Key("ragflow.dataset_ids")
+	attrDocumentIDs         = attribute.Key("ragflow.document_ids")
+	attrTopK                = attribute.Key("ragflow.top_k")
+	attrSimilarityThreshold = attribute.Key("ragflow.similarity_threshold")
+	attrResultCount         = attribute.Key("ragflow.result.count")
+	attrRetryCount          = attribute.Key("ragflow.retry_count")
+	attrCode                = attribute.Key("ragflow.code")
+	attrHTTPMethod          = attribute.Key("http.request.method")
+	attrHTTPStatusCode      = attribute.Key("http.response.status_code")
+	attrURL                 = attribute.Key("url.full")
+)
+
+func (r *Retriever) tracer() trace.Tracer {
+	provider := r.config.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+func (r *Retriever) propagator() propagation.TextMapPropagator {
+	if r.config.Propagator != nil {
+		return r.config.Propagator
+	}
+	return propagation.TraceContext{}
+}
+
+// injectTraceContext 将当前 span 上下文写入请求头, ctx 中没有有效 span 时不做任何修改
+func (r *Retriever) injectTraceContext(ctx context.Context, req *http.Request) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	r.propagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// endSpan 记录错误并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// responseAttributes 从响应中提取状态码与 RAGFlow 业务码
+func responseAttributes(body []byte, err error) []attribute.KeyValue {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return []attribute.KeyValue{attrHTTPStatusCode.Int(httpErr.StatusCode)}
+	}
+	if err != nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{attrHTTPStatusCode.Int(http.StatusOK)}
+	status := &apiResponse{}
+	if sonic.Unmarshal(body, status) == nil {
+		attrs = append(attrs, attrCode.Int(status.Code))
+	}
+	return attrs
+}
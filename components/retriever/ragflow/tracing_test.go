@@ -0,0 +1,93 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	PatchConvey("test Tracing", t, func() {
+		ctx := context.Background()
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		server := newRetrievalServer(staticResponse(
+			Chunk{ID: "1", Content: "c1", Similarity: 0.9},
+			Chunk{ID: "2", Content: "c2", Similarity: 0.8},
+		))
+		defer server.Close()
+
+		PatchConvey("test retrieve and http spans", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:         "key",
+				Endpoint:       server.URL,
+				DatasetIDs:     []string{"kb1", "kb2"},
+				TracerProvider: provider,
+				RetrievalRequestOption: &RetrievalRequestOption{
+					TopK:                ptrOf(5),
+					SimilarityThreshold: ptrOf(0.5),
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			docs, err := r.Retrieve(ctx, "hello")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 2)
+
+			spans := exporter.GetSpans().Snapshots()
+			convey.So(len(spans), convey.ShouldEqual, 2)
+			httpSpan, retrieveSpan := spans[0], spans[1]
+			convey.So(retrieveSpan.Name(), convey.ShouldEqual, "ragflow.Retrieve")
+			convey.So(spanAttr(retrieveSpan, attrQueryLength).AsInt64(), convey.ShouldEqual, 5)
+			convey.So(spanAttr(retrieveSpan, attrDatasetIDs).AsStringSlice(), convey.ShouldResemble, []string{"kb1", "kb2"})
+			convey.So(spanAttr(retrieveSpan, attrTopK).AsInt64(), convey.ShouldEqual, 5)
+			convey.So(spanAttr(retrieveSpan, attrSimilarityThreshold).AsFloat64(), convey.ShouldEqual, 0.5)
+			convey.So(spanAttr(retrieveSpan, attrResultCount).AsInt64(), convey.ShouldEqual, 2)
+
+			convey.So(httpSpan.Parent().SpanID(), convey.ShouldEqual, retrieveSpan.SpanContext().SpanID())
+			convey.So(spanAttr(httpSpan, attrHTTPStatusCode).AsInt64(), convey.ShouldEqual, 200)
+			convey.So(spanAttr(httpSpan, attrCode).AsInt64(), convey.ShouldEqual, 0)
+			convey.So(spanAttr(httpSpan, attrRetryCount).AsInt64(), convey.ShouldEqual, 0)
+
+			traceparent := server.lastHeader().Get("Traceparent")
+			convey.So(traceparent, convey.ShouldContainSubstring, httpSpan.SpanContext().TraceID().String())
+			convey.So(traceparent, convey.ShouldContainSubstring, httpSpan.SpanContext().SpanID().String())
+		})
+
+		PatchConvey("test retry and error recorded", func() {
+			server.apiKey = "valid"
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				Endpoint:       server.URL,
+				DatasetIDs:     []string{"kb1"},
+				TracerProvider: provider,
+				CredentialProvider: CredentialProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+					return "invalid", nil
+				}),
+			})
+			convey.So(err, convey.ShouldBeNil)
+			_, err = r.Retrieve(ctx, "q")
+			convey.So(err, convey.ShouldNotBeNil)
+
+			spans := exporter.GetSpans().Snapshots()
+			convey.So(len(spans), convey.ShouldEqual, 2)
+			httpSpan, retrieveSpan := spans[0], spans[1]
+			convey.So(spanAttr(httpSpan, attrRetryCount).AsInt64(), convey.ShouldEqual, 1)
+			convey.So(spanAttr(httpSpan, attrCode).AsInt64(), convey.ShouldEqual, codeAuthenticationError)
+			convey.So(retrieveSpan.Status().Code, convey.ShouldEqual, codes.Error)
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package ragflow
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cloudwego/eino/components/retriever"
+)
+
+const (
+	// ExtraKeyTwoStage 两阶段检索的过程信息 (*TwoStageInfo), 仅在开启两阶段检索时存在
+	ExtraKeyTwoStage = "two_stage"
+
+	defaultTwoStageDocuments = 5
+)
+
+// TwoStage 定义了两阶段检索: 第一阶段做宽泛检索, 按 DocAggs 选出最相关的文档; 第二阶段只在这些文档中检索
+type TwoStage struct {
+	// Documents 第一阶段选出的文档数, 默认 5
+	Documents int
+	// Stage1PageSize 第一阶段返回的 chunk 数, 仅用于辅助排序文档, 为 0 时使用配置中的 PageSize
+	Stage1PageSize int
+	// Depth 第二阶段返回的 chunk 数, 为 0 时与单阶段检索相同
+	Depth int
+}
+
+// TwoStageInfo 记录两阶段检索的过程, 通过 retriever.CallbackOutput.Extra 返回
+type TwoStageInfo struct {
+	// Stage1Chunks 第一阶段返回的 chunk 数
+	Stage1Chunks int `json:"stage1_chunks"`
+	// Stage1Documents 第一阶段的文档聚合结果
+	Stage1Documents []DocAgg `json:"stage1_documents"`
+	// Stage2DocumentIDs 第二阶段检索的文档, 为空表示第一阶段没有文档聚合结果, 直接使用了第一阶段的结果
+	Stage2DocumentIDs []string `json:"stage2_document_ids"`
+	// Stage2Chunks 第二阶段返回的 chunk 数
+	Stage2Chunks int `json:"stage2_chunks"`
+}
+
+// twoStageSearch 执行两阶段检索, 第一阶段没有文档聚合结果时直接返回第一阶段的结果
+func (r *Retriever) twoStageSearch(ctx context.Context, query string, option *retriever.Options, target *searchTarget,
+	pageSize int, c *TwoStage) (*successResponse, error) {
+	first, err := r.doPost(ctx, query, option, target, c.Stage1PageSize)
+	if err != nil {
+		return nil, err
+	}
+	info := &TwoStageInfo{Stage1Chunks: len(first.Data.Chunks), Stage1Documents: first.Data.DocAggs}
+	defer getCallStats(ctx).setTwoStage(info)
+
+	documents := c.Documents
+	if documents <= 0 {
+		documents = defaultTwoStageDocuments
+	}
+	info.Stage2DocumentIDs = topDocuments(&first.Data, documents)
+	if len(info.Stage2DocumentIDs) == 0 {
+		return first, nil
+	}
+
+	depth := c.Depth
+	if depth <= 0 {
+		depth = pageSize
+	}
+	second, err := r.doPost(ctx, query, option, &searchTarget{
+		DatasetIDs:  target.DatasetIDs,
+		DocumentIDs: info.Stage2DocumentIDs,
+	}, depth)
+	if err != nil {
+		return nil, err
+	}
+	info.Stage2Chunks = len(second.Data.Chunks)
+	return second, nil
+}
+
+// topDocuments 按命中 chunk 数从多到少选出 n 个文档, 数量相同时按第一阶段中最高的相似度排序
+func topDocuments(data *Data, n int) []string {
+	best := map[string]float64{}
+	for _, chunk := range data.Chunks {
+		if s, ok := best[chunk.DocumentID]; !ok || chunk.Similarity > s {
+			best[chunk.DocumentID] = chunk.Similarity
+		}
+	}
+	aggs := append([]DocAgg(nil), data.DocAggs...)
+	sort.SliceStable(aggs, func(i, j int) bool {
+		if aggs[i].Count != aggs[j].Count {
+			return aggs[i].Count > aggs[j].Count
+		}
+		return best[aggs[i].DocID] > best[aggs[j].DocID]
+	})
+	ids := make([]string, 0, n)
+	for _, agg := range aggs {
+		if len(ids) == n {
+			break
+		}
+		if agg.DocID != "" {
+			ids = append(ids, agg.DocID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,74 @@
+package ragflow
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTwoStage(t *testing.T) {
+	PatchConvey("test TwoStage", t, func() {
+		ctx := context.Background()
+		server := newRetrievalServer(func(req *request) *successResponse {
+			if len(req.DocumentIDs) == 0 {
+				return &successResponse{Data: Data{
+					Chunks: []Chunk{
+						{ID: "a1", DocumentID: "A", Similarity: 0.9},
+						{ID: "b1", DocumentID: "B", Similarity: 0.8},
+						{ID: "c1", DocumentID: "C", Similarity: 0.7},
+					},
+					DocAggs: []DocAgg{{DocID: "C", Count: 5}, {DocID: "A", Count: 2}, {DocID: "B", Count: 2}},
+				}}
+			}
+			chunks := make([]Chunk, 0)
+			for _, id := range req.DocumentIDs {
+				chunks = append(chunks, Chunk{ID: id + "2", DocumentID: id, Similarity: 0.6})
+			}
+			return &successResponse{Data: Data{Chunks: chunks}}
+		})
+		defer server.Close()
+
+		PatchConvey("test top documents", func() {
+			data := &Data{
+				Chunks:  []Chunk{{DocumentID: "A", Similarity: 0.5}, {DocumentID: "B", Similarity: 0.9}},
+				DocAggs: []DocAgg{{DocID: "A", Count: 1}, {DocID: "B", Count: 1}, {DocID: "C", Count: 3}},
+			}
+			convey.So(topDocuments(data, 2), convey.ShouldResemble, []string{"C", "B"})
+			convey.So(topDocuments(&Data{}, 2), convey.ShouldBeEmpty)
+		})
+
+		PatchConvey("test retrieve with two stage", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:     "key",
+				Endpoint:   server.URL,
+				DatasetIDs: []string{"kb1"},
+				TwoStage:   &TwoStage{Documents: 2, Stage1PageSize: 50, Depth: 8},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			cbCtx, rec := newCallbackContext(ctx)
+			docs, err := r.Retrieve(cbCtx, "q")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(docIDs(docs), convey.ShouldResemble, []string{"C", "A"})
+
+			reqs := server.received()
+			convey.So(len(reqs), convey.ShouldEqual, 2)
+			convey.So(*reqs[0].PageSize, convey.ShouldEqual, 50)
+			convey.So(reqs[1].DocumentIDs, convey.ShouldResemble, []string{"C", "A"})
+			convey.So(reqs[1].DatasetIDs, convey.ShouldResemble, []string{"kb1"})
+			convey.So(*reqs[1].PageSize, convey.ShouldEqual, 8)
+
+			info := rec.output.Extra[ExtraKeyTwoStage].(*TwoStageInfo)
+			convey.So(info.Stage1Chunks, convey.ShouldEqual, 3)
+			convey.So(len(info.Stage1Documents), convey.ShouldEqual, 3)
+			convey.So(info.Stage2DocumentIDs, convey.ShouldResemble, []string{"C", "A"})
+			convey.So(info.Stage2Chunks, convey.ShouldEqual, 2)
+
+			docs, err = r.Retrieve(ctx, "q", WithTwoStage(nil))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(docs), convey.ShouldEqual, 3)
+			convey.So(len(server.received()), convey.ShouldEqual, 3)
+		})
+	})
+}
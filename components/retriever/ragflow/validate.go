@@ -0,0 +1,170 @@
+package ragflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const documentRunDone = "DONE"
+
+// ValidationIssueKind 是校验问题的类型
+type ValidationIssueKind string
+
+const (
+	// IssueAuth API Key 无效或无权限
+	IssueAuth ValidationIssueKind = "auth"
+	// IssueNameResolution DatasetNames/DocumentNames 无法解析
+	IssueNameResolution ValidationIssueKind = "name_resolution"
+	// IssueDatasetNotFound 数据集不存在或不可访问
+	IssueDatasetNotFound ValidationIssueKind = "dataset_not_found"
+	// IssueDatasetEmpty 数据集中没有已解析的 chunk
+	IssueDatasetEmpty ValidationIssueKind = "dataset_empty"
+	// IssueDocumentNotFound 文档不存在或不可访问
+	IssueDocumentNotFound ValidationIssueKind = "document_not_found"
+	// IssueDocumentNotParsed 文档尚未完成解析
+	IssueDocumentNotParsed ValidationIssueKind = "document_not_parsed"
+	// IssueEmbeddingModelMismatch 选中的数据集与文档使用了不同的 embedding 模型
+	IssueEmbeddingModelMismatch ValidationIssueKind = "embedding_model_mismatch"
+)
+
+// ValidationIssue 是一条校验问题
+type ValidationIssue struct {
+	Kind ValidationIssueKind `json:"kind"`
+	// ID 是出问题的数据集或文档 ID, 与具体对象无关时为空
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationReport 是 Validate 的结果
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+	// EmbeddingModels 是选中的数据集所使用的 embedding 模型
+	EmbeddingModels []string `json:"embedding_models"`
+}
+
+// OK 表示没有发现任何问题
+func (x *ValidationReport) OK() bool {
+	return x == nil || len(x.Issues) == 0
+}
+
+// Err 将报告中的问题合并为一个 error, 没有问题时返回 nil
+func (x *ValidationReport) Err() error {
+	if x.OK() {
+		return nil
+	}
+	msgs := make([]string, 0, len(x.Issues))
+	for _, issue := range x.Issues {
+		msgs = append(msgs, fmt.Sprintf("[%s] %s", issue.Kind, issue.Message))
+	}
+	return fmt.Errorf("ragflow validation failed: %s", strings.Join(msgs, "; "))
+}
+
+func (x *ValidationReport) add(kind ValidationIssueKind, id, format string, args ...any) {
+	x.Issues = append(x.Issues, ValidationIssue{Kind: kind, ID: id, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate 检查 API Key 是否有效, 选中的数据集与文档是否存在且可访问, 文档是否已解析完成,
+// 以及它们是否使用同一个 embedding 模型. 发现的问题记录在报告中, 仅在请求本身失败时返回 error
+func (r *Retriever) Validate(ctx context.Context) (*ValidationReport, error) {
+	report := &ValidationReport{}
+	datasets, err := r.ListDatasets(ctx, "")
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			report.add(IssueAuth, "", "list datasets failed: %s", apiErr.Message)
+			return report, nil
+		}
+		return nil, err
+	}
+	byID := make(map[string]Dataset, len(datasets))
+	for _, d := range datasets {
+		byID[d.ID] = d
+	}
+
+	target, err := r.getSearchTarget(ctx)
+	if err != nil {
+		report.add(IssueNameResolution, "", "%v", err)
+		target = &searchTarget{DatasetIDs: r.config.DatasetIDs, DocumentIDs: r.config.DocumentIDs}
+	}
+
+	models := make(map[string][]string)
+	candidates := make([]string, 0, len(target.DatasetIDs))
+	for _, id := range target.DatasetIDs {
+		d, ok := byID[id]
+		if !ok {
+			report.add(IssueDatasetNotFound, id, "dataset %s not found or not accessible", id)
+			continue
+		}
+		candidates = append(candidates, id)
+		models[d.EmbeddingModel] = append(models[d.EmbeddingModel], id)
+		if d.ChunkCount == 0 {
+			report.add(IssueDatasetEmpty, id, "dataset %s has no parsed chunks", id)
+		}
+	}
+	if len(target.DatasetIDs) == 0 {
+		for _, d := range datasets {
+			candidates = append(candidates, d.ID)
+		}
+	}
+
+	for _, id := range target.DocumentIDs {
+		doc, err := r.findDocument(ctx, candidates, id)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			report.add(IssueDocumentNotFound, id, "document %s not found or not accessible", id)
+			continue
+		}
+		if doc.Run != documentRunDone {
+			report.add(IssueDocumentNotParsed, id, "document %s is not parsed, run status: %s", id, doc.Run)
+		}
+		if d, ok := byID[doc.DatasetID]; ok && !slices.Contains(models[d.EmbeddingModel], d.ID) {
+			models[d.EmbeddingModel] = append(models[d.EmbeddingModel], d.ID)
+		}
+	}
+
+	for model := range models {
+		report.EmbeddingModels = append(report.EmbeddingModels, model)
+	}
+	sort.Strings(report.EmbeddingModels)
+	if len(models) > 1 {
+		usage := make([]string, 0, len(models))
+		for _, model := range report.EmbeddingModels {
+			usage = append(usage, fmt.Sprintf("%s(%s)", model, strings.Join(models[model], ",")))
+		}
+		report.add(IssueEmbeddingModelMismatch, "", "selected datasets use different embedding models: %s", strings.Join(usage, ", "))
+	}
+	return report, nil
+}
+
+// findDocument 在候选数据集中查找文档, 找不到时返回 nil
+func (r *Retriever) findDocument(ctx context.Context, datasetIDs []string, documentID string) (*DatasetDocument, error) {
+	for _, datasetID := range datasetIDs {
+		res := &listDocumentsResponse{}
+		path := fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetID))
+		if err := r.getJSON(ctx, r.apiURL(path, url.Values{"id": {documentID}}), res); err != nil {
+			// 文档不属于该数据集时 RAGFlow 返回业务错误
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				continue
+			}
+			return nil, err
+		}
+		for i := range res.Data.Docs {
+			if res.Data.Docs[i].ID == documentID {
+				doc := res.Data.Docs[i]
+				if doc.DatasetID == "" {
+					doc.DatasetID = datasetID
+				}
+				return &doc, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package ragflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/bytedance/mockey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	PatchConvey("test Validate", t, func() {
+		ctx := context.Background()
+		server := newNameServer([]Dataset{
+			{ID: "kb1", Name: "manual", EmbeddingModel: "bge-m3", ChunkCount: 10},
+			{ID: "kb2", Name: "faq", EmbeddingModel: "text-embedding-3", ChunkCount: 0},
+		}, map[string][]DatasetDocument{
+			"kb1": {{ID: "d1", Name: "a.pdf", Run: "DONE"}, {ID: "d2", Name: "b.pdf", Run: "RUNNING"}},
+		})
+		defer server.Close()
+
+		PatchConvey("test ok", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:           "test",
+				Endpoint:         server.URL,
+				DatasetIDs:       []string{"kb1"},
+				DocumentIDs:      []string{"d1"},
+				ValidateOnCreate: true,
+			})
+			convey.So(err, convey.ShouldBeNil)
+			report, err := r.Validate(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(report.OK(), convey.ShouldBeTrue)
+			convey.So(report.EmbeddingModels, convey.ShouldResemble, []string{"bge-m3"})
+		})
+
+		PatchConvey("test issues", func() {
+			r, err := NewRetriever(ctx, &RetrieverConfig{
+				APIKey:      "test",
+				Endpoint:    server.URL,
+				DatasetIDs:  []string{"kb1", "kb2", "kb9"},
+				DocumentIDs: []string{"d2", "d9"},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			report, err := r.Validate(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			kinds := make([]ValidationIssueKind, 0, len(report.Issues))
+			for _, issue := range report.Issues {
+				kinds = append(kinds, issue.Kind)
+			}
+			convey.So(kinds, convey.ShouldResemble, []ValidationIssueKind{
+				IssueDatasetEmpty, IssueDatasetNotFound, IssueDocumentNotParsed, IssueDocumentNotFound, IssueEmbeddingModelMismatch,
+			})
+
+			r.config.ValidateOnCreate = true
+			_, err = NewRetriever(ctx, r.config)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "[embedding_model_mismatch]")
+		})
+
+		PatchConvey("test invalid api key", func() {
+			authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				_, _ = w.Write([]byte(`{"code":109,"message":"Authentication error: API key is invalid!","data":false}`))
+			}))
+			defer authServer.Close()
+			r, err := NewRetriever(ctx, &RetrieverConfig{APIKey: "test", Endpoint: authServer.URL, DatasetIDs: []string{"kb1"}})
+			convey.So(err, convey.ShouldBeNil)
+			report, err := r.Validate(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(report.Issues[0].Kind, convey.ShouldEqual, IssueAuth)
+		})
+	})
+}